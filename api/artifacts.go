@@ -0,0 +1,70 @@
+package api
+
+import (
+	"database/sql"
+	"db"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"storage"
+	"strconv"
+	"time"
+)
+
+type ArtifactHandler struct {
+	store storage.Store
+
+	conn *db.Connection
+}
+
+func (handler *ArtifactHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+	if err != nil {
+		writeBadRequestError(err, w, r)
+		return
+	}
+
+	artifact, err := handler.conn.GetArtifact(id, r.PathValue("name"))
+	if err == sql.ErrNoRows {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		writeInternalServerError(err, w, r)
+		return
+	}
+
+	reader, err := handler.store.Open(artifact.Key)
+	if err != nil {
+		writeInternalServerError(err, w, r)
+		return
+	}
+	defer reader.Close()
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set(
+		"Content-Disposition",
+		mime.FormatMediaType("attachment", map[string]string{"filename": path.Base(artifact.Name)}),
+	)
+	http.ServeContent(w, r, artifact.Name, time.Time{}, reader)
+}
+
+func NewArtifactHandler(conn *db.Connection, store storage.Store) (*ArtifactHandler, error) {
+	if err := checkConnection(conn); err != nil {
+		return nil, err
+	}
+	if err := checkStore(store); err != nil {
+		return nil, err
+	}
+
+	h := new(ArtifactHandler)
+	h.store = store
+	h.conn = conn
+	return h, nil
+}
+
+func artifactUrl(id uint64, name string) string {
+	return fmt.Sprintf("/api/commands/%d/artifacts/%s", id, (&url.URL{Path: name}).EscapedPath())
+}
@@ -0,0 +1,273 @@
+package api
+
+import (
+	"db"
+	"encoding/json"
+	"executor"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// Maximal amount of commands in one batch
+const MaxBatchSize = 1000
+
+type BatchHandler struct {
+	executeHandler *ExecuteHandler
+
+	conn *db.Connection
+}
+
+type GetBatchHandler struct {
+	conn *db.Connection
+}
+
+type CancelBatchHandler struct {
+	cancelHandler *CancelHandler
+
+	conn *db.Connection
+}
+
+// Launches all commands of the batch. Commands are stored in one
+// transaction, so either all of them are launched or none.
+func (handler *BatchHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	batchBody := new(BatchRequestBody)
+	if err := json.NewDecoder(r.Body).Decode(batchBody); err != nil {
+		writeBadRequestError(err, w, r)
+		return
+	}
+
+	requestBodies, err := batchBody.expand()
+	if err != nil {
+		writeBadRequestError(err, w, r)
+		return
+	}
+
+	// created workspaces are removed if batch isn't launched
+	launched := false
+	defer func() {
+		if !launched {
+			for _, requestBody := range requestBodies {
+				handler.executeHandler.removeWorkspace(requestBody)
+			}
+		}
+	}()
+
+	now := time.Now()
+	commands := make([]db.CommandTableRecord, 0, len(requestBodies))
+	inputs := make([]db.InputTableRecord, 0, len(requestBodies))
+	for i, requestBody := range requestBodies {
+		if err := handler.executeHandler.prepare(requestBody); err != nil {
+			writeBadRequestError(fmt.Errorf("command #%d: %w", i, err), w, r)
+			return
+		}
+
+		commands = append(commands, db.CommandTableRecord{
+			Command: requestBody.Command,
+			Group:   requestBody.Group,
+			RunAt:   requestBody.launchTime(now),
+		})
+		inputs = append(inputs, newInputRecord(requestBody))
+	}
+
+	// writing database records
+	batchId, ids, err := handler.conn.InsertBatch(commands, inputs)
+	if err != nil {
+		log.Println(err)
+		writeInternalServerError(err, w, r)
+		return
+	}
+	launched = true
+
+	response := BatchResponse{
+		Id:       batchId,
+		Commands: make([]LaunchResponse, 0, len(ids)),
+		Links:    newBatchLinks(batchId),
+	}
+	for i, id := range ids {
+		statuses := db.StatusesTableRecord{Status: db.StatusRunning}
+
+		requestBody := requestBodies[i]
+		if commands[i].RunAt != nil {
+			statuses.Status = db.StatusScheduled
+		} else if _, err := handler.executeHandler.start(id, requestBody); err != nil {
+			statuses = newStartFailureStatuses(err)
+		}
+
+		response.Commands = append(response.Commands, newLaunchResponse(id, statuses))
+	}
+	log.Printf("launched batch with id = %d of %d commands\n", batchId, len(ids))
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", response.Links.Status)
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(&response)
+}
+
+func (handler *GetBatchHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	batchId, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+	if err != nil {
+		writeBadRequestError(err, w, r)
+		return
+	}
+
+	commands, err := handler.conn.GetBatchCommands(batchId)
+	if err != nil {
+		writeInternalServerError(err, w, r)
+		return
+	}
+	if len(commands) == 0 {
+		http.NotFound(w, r)
+		return
+	}
+
+	json.NewEncoder(w).Encode(newGroupStatus(commands))
+}
+
+// Cancels every running command of the batch.
+func (handler *CancelBatchHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	batchId, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+	if err != nil {
+		writeBadRequestError(err, w, r)
+		return
+	}
+
+	commands, err := handler.conn.GetBatchCommands(batchId)
+	if err != nil {
+		writeInternalServerError(err, w, r)
+		return
+	}
+	if len(commands) == 0 {
+		http.NotFound(w, r)
+		return
+	}
+
+	json.NewEncoder(w).Encode(CancelGroupResponse{
+		Cancelled: cancelCommands(handler.cancelHandler, commands, r.RemoteAddr),
+	})
+}
+
+func NewBatchHandler(conn *db.Connection, executeHandler *ExecuteHandler) (*BatchHandler, error) {
+	if err := checkConnection(conn); err != nil {
+		return nil, err
+	}
+	if err := checkExecuteHandler(executeHandler); err != nil {
+		return nil, err
+	}
+
+	h := new(BatchHandler)
+	h.executeHandler = executeHandler
+	h.conn = conn
+	return h, nil
+}
+
+func NewGetBatchHandler(conn *db.Connection) (*GetBatchHandler, error) {
+	if err := checkConnection(conn); err != nil {
+		return nil, err
+	}
+
+	h := new(GetBatchHandler)
+	h.conn = conn
+	return h, nil
+}
+
+func NewCancelBatchHandler(conn *db.Connection, cancelHandler *CancelHandler) (*CancelBatchHandler, error) {
+	if err := checkConnection(conn); err != nil {
+		return nil, err
+	}
+	if err := checkCancelHandler(cancelHandler); err != nil {
+		return nil, err
+	}
+
+	h := new(CancelBatchHandler)
+	h.cancelHandler = cancelHandler
+	h.conn = conn
+	return h, nil
+}
+
+// Request body of the batch launch. Either list of commands or template
+// with parameters matrix must be provided.
+//
+// Every combination of matrix values launches template command with
+// parameters passed as environment variables.
+type BatchRequestBody struct {
+	Commands []RequestBody `json:"commands"`
+
+	Template *RequestBody        `json:"template"`
+	Matrix   map[string][]string `json:"matrix"`
+}
+
+// Returns requests of all commands of the batch.
+func (batchBody *BatchRequestBody) expand() ([]*RequestBody, error) {
+	if len(batchBody.Commands) != 0 && batchBody.Template != nil {
+		return nil, fmt.Errorf("\"commands\" and \"template\" parameters can't be used together")
+	}
+
+	var requestBodies []*RequestBody
+	if batchBody.Template != nil {
+		for _, parameters := range expandMatrix(batchBody.Matrix) {
+			requestBody := batchBody.Template.clone()
+			requestBody.Env = append(requestBody.Env, parameters...)
+			requestBodies = append(requestBodies, requestBody)
+		}
+	} else {
+		for i := range batchBody.Commands {
+			requestBodies = append(requestBodies, batchBody.Commands[i].clone())
+		}
+	}
+
+	if len(requestBodies) == 0 {
+		return nil, fmt.Errorf("batch must contain at least one command")
+	}
+	if len(requestBodies) > MaxBatchSize {
+		return nil, fmt.Errorf("batch can't contain more than %d commands", MaxBatchSize)
+	}
+
+	return requestBodies, nil
+}
+
+// Returns every combination of matrix values. Empty matrix has one empty
+// combination.
+func expandMatrix(matrix map[string][]string) [][]executor.EnvironmentEntry {
+	combinations := [][]executor.EnvironmentEntry{{}}
+
+	keys := make([]string, 0, len(matrix))
+	for key := range matrix {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+
+	for _, key := range keys {
+		expanded := make([][]executor.EnvironmentEntry, 0, len(combinations)*len(matrix[key]))
+		for _, combination := range combinations {
+			for _, value := range matrix[key] {
+				entry := executor.EnvironmentEntry{Key: key, Val: value}
+				expanded = append(expanded, append(slices.Clip(combination), entry))
+			}
+		}
+
+		combinations = expanded
+		if len(combinations) > MaxBatchSize {
+			break
+		}
+	}
+
+	return combinations
+}
+
+// Response body of the batch launch.
+type BatchResponse struct {
+	Id       uint64           `json:"id"`
+	Commands []LaunchResponse `json:"commands"`
+	Links    GroupLinks       `json:"links"`
+}
+
+func newBatchLinks(batchId uint64) GroupLinks {
+	return GroupLinks{
+		Status: fmt.Sprintf("/api/batches/%d", batchId),
+		Cancel: fmt.Sprintf("/api/batches/%d/cancel", batchId),
+	}
+}
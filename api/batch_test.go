@@ -0,0 +1,72 @@
+package api
+
+import (
+	"executor"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// Formats combinations as "A=1,B=2;A=1,B=3".
+func formatCombinations(combinations [][]executor.EnvironmentEntry) string {
+	formatted := make([]string, 0, len(combinations))
+	for _, combination := range combinations {
+		entries := make([]string, 0, len(combination))
+		for _, entry := range combination {
+			entries = append(entries, entry.Key+"="+entry.Val)
+		}
+		formatted = append(formatted, strings.Join(entries, ","))
+	}
+
+	return strings.Join(formatted, ";")
+}
+
+func TestExpandMatrix(t *testing.T) {
+	cases := []struct {
+		name         string
+		matrix       map[string][]string
+		combinations string
+		count        int
+	}{
+		{name: "nil", matrix: nil, combinations: "", count: 1},
+		{name: "single", matrix: map[string][]string{"OS": {"linux", "mac"}}, combinations: "OS=linux;OS=mac", count: 2},
+		{
+			name:         "product",
+			matrix:       map[string][]string{"B": {"1", "2"}, "A": {"amogus", "sus"}},
+			combinations: "A=amogus,B=1;A=amogus,B=2;A=sus,B=1;A=sus,B=2",
+			count:        4,
+		},
+		{name: "empty axis", matrix: map[string][]string{"A": {"amogus"}, "B": {}}, combinations: "", count: 0},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			combinations := expandMatrix(c.matrix)
+			if len(combinations) != c.count {
+				t.Fatalf("number of combinations must be %d, got %d", c.count, len(combinations))
+			}
+			if formatted := formatCombinations(combinations); formatted != c.combinations {
+				t.Fatalf("combinations must be \"%s\", got \"%s\"", c.combinations, formatted)
+			}
+		})
+	}
+}
+
+func TestExpandMatrixLimit(t *testing.T) {
+	values := make([]string, 11)
+	for i := range values {
+		values[i] = strconv.Itoa(i)
+	}
+	matrix := map[string][]string{"A": values, "B": values, "C": values, "D": values}
+
+	// expansion stops at the first product exceeding the limit
+	combinations := expandMatrix(matrix)
+	if len(combinations) != 11*11*11 {
+		t.Fatalf("number of combinations must be %d, got %d", 11*11*11, len(combinations))
+	}
+
+	batchBody := BatchRequestBody{Template: &RequestBody{Command: "echo sus"}, Matrix: matrix}
+	if _, err := batchBody.expand(); err == nil {
+		t.Fatalf("batch larger than %d commands must be rejected", MaxBatchSize)
+	}
+}
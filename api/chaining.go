@@ -0,0 +1,101 @@
+package api
+
+import (
+	"database/sql"
+	"db"
+	"executor"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+// Resolves references to other commands: "stdin_from" command is checked,
+// its output is streamed into the command at launch, and outputs of
+// "env_from" commands become values of environment variables without
+// trailing newlines.
+func (handler *ExecuteHandler) resolveOutputs(requestBody *RequestBody) error {
+	if requestBody.StdinFrom != 0 {
+		if _, err := handler.getSucceededOutputs(requestBody.StdinFrom); err != nil {
+			return fmt.Errorf("\"stdin_from\": %w", err)
+		}
+	}
+
+	keys := make([]string, 0, len(requestBody.EnvFrom))
+	for key := range requestBody.EnvFrom {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+
+	for _, key := range keys {
+		output, err := handler.readOutput(requestBody.EnvFrom[key])
+		if err != nil {
+			return fmt.Errorf("\"env_from\" variable \"%s\": %w", key, err)
+		}
+
+		requestBody.Env = append(
+			slices.Clip(requestBody.Env),
+			executor.EnvironmentEntry{Key: key, Val: strings.TrimRight(output, "\n")},
+		)
+	}
+
+	return nil
+}
+
+// Returns input of the command: stdout of "stdin_from" command as is,
+// either from the database or from the storage, or "input" parameter.
+func (handler *ExecuteHandler) openStdin(requestBody *RequestBody) (io.Reader, error) {
+	if requestBody.StdinFrom == 0 {
+		return strings.NewReader(requestBody.Input), nil
+	}
+
+	outputs, err := handler.conn.GetOutputsById(requestBody.StdinFrom)
+	if err != nil {
+		return nil, fmt.Errorf("output of command with id = %d can't be read: %w", requestBody.StdinFrom, err)
+	}
+
+	return handler.outputsOpener(&outputs)("stdout")
+}
+
+// Returns stdout of the successfully finished command as environment
+// variable value. Output must be kept in the database and must be a text.
+func (handler *ExecuteHandler) readOutput(id uint64) (string, error) {
+	outputs, err := handler.getSucceededOutputs(id)
+	if err != nil {
+		return "", err
+	}
+
+	if outputs.OutputKey != "" {
+		return "", fmt.Errorf("output of command with id = %d is too large to be passed", id)
+	}
+	if !utf8.Valid(outputs.Output) || slices.Contains(outputs.Output, 0) {
+		return "", fmt.Errorf("output of command with id = %d isn't a text", id)
+	}
+
+	return string(outputs.Output), nil
+}
+
+// Returns outputs of the command if it's finished successfully.
+func (handler *ExecuteHandler) getSucceededOutputs(id uint64) (db.OutputsTableRecord, error) {
+	record, err := handler.conn.GetFullRecordById(id)
+	if err == sql.ErrNoRows {
+		return record.Outputs, fmt.Errorf("command with id = %d doesn't exist", id)
+	}
+	if err != nil {
+		return record.Outputs, err
+	}
+
+	if !isSucceeded(record.Statuses) {
+		return record.Outputs, fmt.Errorf("command with id = %d isn't finished successfully", id)
+	}
+
+	return record.Outputs, nil
+}
+
+// Closes input of the command if it's opened from the storage.
+func closeInput(stdin io.Reader) {
+	if closer, ok := stdin.(io.Closer); ok {
+		closer.Close()
+	}
+}
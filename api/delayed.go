@@ -0,0 +1,105 @@
+package api
+
+import (
+	"db"
+	"encoding/json"
+	"retry"
+	"time"
+	"verdict"
+)
+
+// Returns time when command must be launched or nil if it must be launched
+// immediately.
+func (requestBody *RequestBody) launchTime(now time.Time) *time.Time {
+	var runAt time.Time
+	switch {
+	case requestBody.RunAt != nil:
+		runAt = *requestBody.RunAt
+	case requestBody.DelaySeconds > 0:
+		runAt = now.Add(seconds(requestBody.DelaySeconds))
+	default:
+		return nil
+	}
+
+	if !runAt.After(now) {
+		return nil
+	}
+
+	return &runAt
+}
+
+func (requestBody *RequestBody) isDelayed() bool {
+	return requestBody.RunAt != nil || requestBody.DelaySeconds != 0
+}
+
+// Launches delayed command that is stored in the database. Command is
+// launched only once even if several server instances try to launch it.
+func (handler *ExecuteHandler) launchDelayed(id uint64) error {
+	record, err := handler.conn.GetFullRecordById(id)
+	if err != nil {
+		return err
+	}
+
+	requestBody, err := newStoredRequestBody(record)
+	if err != nil {
+		return err
+	}
+
+	claimed, err := handler.conn.ClaimScheduledCommand(id)
+	if err != nil || !claimed {
+		return err
+	}
+
+	_, err = handler.start(id, requestBody)
+	return err
+}
+
+// Restores request of the command from its record. Working directory and
+// "env_from" outputs are already resolved, "stdin_from" output is read at
+// launch.
+func newStoredRequestBody(record db.FullCommandRecord) (*RequestBody, error) {
+	requestBody := &RequestBody{
+		Workdir:   record.Input.Workdir,
+		Env:       record.Input.Env,
+		Input:     record.Input.Input,
+		Command:   record.Command.Command,
+		Group:     record.Command.Group,
+		Artifacts: record.Input.Artifacts,
+		StdinFrom: record.Input.StdinFrom,
+
+		TimeoutSeconds: record.Input.TimeoutSeconds,
+	}
+
+	// commands stored before streaming keep resolved output as input
+	if requestBody.StdinFrom != 0 {
+		requestBody.Input = ""
+	}
+
+	if record.Input.Retry != nil {
+		requestBody.Retry = new(retry.Policy)
+		if err := json.Unmarshal(record.Input.Retry, requestBody.Retry); err != nil {
+			return nil, err
+		}
+	}
+	if record.Input.Success != nil {
+		requestBody.Success = new(verdict.Criteria)
+		if err := json.Unmarshal(record.Input.Success, requestBody.Success); err != nil {
+			return nil, err
+		}
+		// compiling patterns of the assertions
+		if err := requestBody.Success.Validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	if workspace := record.Input.Workspace; workspace != nil {
+		requestBody.Workspace = &WorkspaceOptions{
+			Template:    workspace.Template,
+			Cleanup:     workspace.Cleanup,
+			RetainHours: workspace.RetainHours,
+		}
+		requestBody.workspace = workspace.Path
+	}
+
+	return requestBody, nil
+}
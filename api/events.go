@@ -0,0 +1,79 @@
+package api
+
+import (
+	"database/sql"
+	"db"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+)
+
+type EventsHandler struct {
+	conn *db.Connection
+}
+
+// Returns history of the command.
+func (handler *EventsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+	if err != nil {
+		writeBadRequestError(err, w, r)
+		return
+	}
+
+	events, err := handler.conn.GetEvents(id)
+	if err != nil {
+		writeInternalServerError(err, w, r)
+		return
+	}
+
+	// commands stored before events have no history
+	if len(events) == 0 {
+		_, err := handler.conn.GetStatusesById(id)
+		if err == sql.ErrNoRows {
+			http.NotFound(w, r)
+			return
+		}
+		if err != nil {
+			writeInternalServerError(err, w, r)
+			return
+		}
+	}
+
+	json.NewEncoder(w).Encode(EventsResponse{Id: id, Events: events})
+}
+
+func NewEventsHandler(conn *db.Connection) (*EventsHandler, error) {
+	if err := checkConnection(conn); err != nil {
+		return nil, err
+	}
+
+	h := new(EventsHandler)
+	h.conn = conn
+	return h, nil
+}
+
+// Response body of the command's history.
+type EventsResponse struct {
+	Id     uint64                `json:"id"`
+	Events []db.EventTableRecord `json:"events"`
+}
+
+// Details of the event requested by the client.
+type requestEventData struct {
+	// Address of the client that requested the event.
+	RemoteAddr string `json:"remote_addr"`
+}
+
+// Appends event with data into the history of the command. History is
+// auxiliary, so errors are only logged.
+func insertEvent(conn *db.Connection, id uint64, eventType string, data any) {
+	event := db.EventTableRecord{Type: eventType}
+	if data != nil {
+		event.Data, _ = json.Marshal(data)
+	}
+
+	if err := conn.InsertEvent(id, event); err != nil {
+		log.Printf("command with id = %d can't store %s event: %s\n", id, eventType, err)
+	}
+}
@@ -0,0 +1,125 @@
+package api
+
+import (
+	"db"
+	"encoding/json"
+	"net/http"
+	"regexp"
+)
+
+// Allowed names of the groups: they are used in URLs as is
+var groupNameRegexp = regexp.MustCompile(`^[A-Za-z0-9._-]{1,128}$`)
+
+type GetGroupHandler struct {
+	conn *db.Connection
+}
+
+type CancelGroupHandler struct {
+	cancelHandler *CancelHandler
+
+	conn *db.Connection
+}
+
+func (handler *GetGroupHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	commands, err := handler.conn.GetGroupCommands(r.PathValue("name"))
+	if err != nil {
+		writeInternalServerError(err, w, r)
+		return
+	}
+	if len(commands) == 0 {
+		http.NotFound(w, r)
+		return
+	}
+
+	json.NewEncoder(w).Encode(newGroupStatus(commands))
+}
+
+// Cancels every running command of the group.
+func (handler *CancelGroupHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	commands, err := handler.conn.GetGroupCommands(r.PathValue("name"))
+	if err != nil {
+		writeInternalServerError(err, w, r)
+		return
+	}
+	if len(commands) == 0 {
+		http.NotFound(w, r)
+		return
+	}
+
+	json.NewEncoder(w).Encode(CancelGroupResponse{
+		Cancelled: cancelCommands(handler.cancelHandler, commands, r.RemoteAddr),
+	})
+}
+
+func NewGetGroupHandler(conn *db.Connection) (*GetGroupHandler, error) {
+	if err := checkConnection(conn); err != nil {
+		return nil, err
+	}
+
+	h := new(GetGroupHandler)
+	h.conn = conn
+	return h, nil
+}
+
+func NewCancelGroupHandler(conn *db.Connection, cancelHandler *CancelHandler) (*CancelGroupHandler, error) {
+	if err := checkConnection(conn); err != nil {
+		return nil, err
+	}
+	if err := checkCancelHandler(cancelHandler); err != nil {
+		return nil, err
+	}
+
+	h := new(CancelGroupHandler)
+	h.cancelHandler = cancelHandler
+	h.conn = conn
+	return h, nil
+}
+
+func isValidGroupName(name string) bool {
+	return groupNameRegexp.MatchString(name)
+}
+
+// Links to the endpoints of the group of commands.
+type GroupLinks struct {
+	Status string `json:"status"`
+	Cancel string `json:"cancel"`
+}
+
+// Statuses of the group of commands.
+type GroupStatus struct {
+	Commands []db.CommandSummaryRecord `json:"commands"`
+
+	// Amount of commands in every lifecycle status.
+	Counts map[string]int `json:"counts"`
+}
+
+func newGroupStatus(commands []db.CommandSummaryRecord) GroupStatus {
+	status := GroupStatus{Commands: commands, Counts: make(map[string]int)}
+	for _, command := range commands {
+		status.Counts[command.Statuses.Status]++
+	}
+
+	return status
+}
+
+// Response body of the group cancellation.
+type CancelGroupResponse struct {
+	Cancelled []uint64 `json:"cancelled"`
+}
+
+// Cancels commands that are running on this server and delayed commands.
+// Returns IDs of cancelled commands.
+func cancelCommands(cancelHandler *CancelHandler, commands []db.CommandSummaryRecord, requester string) []uint64 {
+	cancelled := make([]uint64, 0)
+	for _, command := range commands {
+		if db.IsTerminalStatus(command.Statuses.Status) {
+			continue
+		}
+
+		if err := cancelHandler.cancel(command.Command.Id, requester); err == nil {
+			cancelled = append(cancelled, command.Command.Id)
+		}
+	}
+
+	return cancelled
+}
@@ -1,25 +1,37 @@
 package api
 
 import (
-	"bytes"
 	"context"
+	"crypto/sha256"
 	"database/sql"
 	"db"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"executor"
 	"fmt"
+	"io"
+	"io/fs"
 	"log"
+	"maps"
 	"net/http"
+	"os"
 	"os/exec"
+	"path/filepath"
+	"retry"
+	"slices"
+	"storage"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
+	"verdict"
+	"workspace"
 )
 
 type CancelHandler struct {
-	cancelFuncs map[uint64]context.CancelFunc
-	locker      sync.Locker
+	commands map[uint64]*runningCommand
+	locker   sync.Locker
 
 	conn *db.Connection
 }
@@ -27,6 +39,17 @@ type CancelHandler struct {
 type ExecuteHandler struct {
 	cancelHandler *CancelHandler
 
+	// outputs larger than threshold are moved into the store
+	store           storage.Store
+	outputThreshold int
+
+	// fresh working directories for commands with attached files
+	workspaces *workspace.Manager
+
+	// name of this server instance that is stored with its running
+	// commands
+	instance string
+
 	conn *db.Connection
 }
 
@@ -38,6 +61,10 @@ type GetFullCommandHandler struct {
 	conn *db.Connection
 }
 
+type StorageHandler struct {
+	store storage.Store
+}
+
 func (handler *CancelHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	urlValues := r.URL.Query()
 	stringId := urlValues.Get("id")
@@ -48,7 +75,7 @@ func (handler *CancelHandler) ServeHTTP(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	if err := handler.callAndDelete(id); err != nil {
+	if err := handler.cancel(id, r.RemoteAddr); err != nil {
 		http.NotFound(w, r)
 		return
 	}
@@ -57,101 +84,62 @@ func (handler *CancelHandler) ServeHTTP(w http.ResponseWriter, r *http.Request)
 }
 
 func (handler *ExecuteHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	requestBody := new(RequestBody)
-	err := json.NewDecoder(r.Body).Decode(requestBody)
-	if err != nil {
+	if _, err := parseWaitTimeout(r.URL.Query().Get("timeout")); err != nil {
 		writeBadRequestError(err, w, r)
 		return
 	}
 
-	if requestBody.Command == "" {
-		writeBadRequestError(fmt.Errorf("\"command\" parameter must be not empty"), w, r)
+	if isMultipartRequest(r) {
+		handler.serveMultipart(w, r)
 		return
 	}
 
-	// writing database record
-	id, err := handler.conn.InsertRecord(
-		db.CommandTableRecord{Command: requestBody.Command},
-		db.InputTableRecord{Input: requestBody.Input, Env: requestBody.Env},
-	)
+	body, err := io.ReadAll(r.Body)
 	if err != nil {
-		log.Println(err)
-		writeInternalServerError(err, w, r)
+		writeBadRequestError(err, w, r)
 		return
 	}
 
-	// populating cancel functions map
-	ctx, cancel := context.WithCancel(context.Background())
-	handler.cancelHandler.insert(id, cancel)
-
-	// preparing streams
-	outWriter := new(bytes.Buffer)
-	errWriter := new(bytes.Buffer)
-
-	// launching command
-	executor := executor.Executor{Workdir: requestBody.Workdir, Env: requestBody.Env}
-	isDone := executor.RunScript(
-		ctx,
-		strings.NewReader(requestBody.Input),
-		outWriter,
-		errWriter,
-		requestBody.Command,
-	)
-	log.Printf("launched command with id = %d", id)
-
-	outputs := new(db.OutputsTableRecord)
+	requestBody := new(RequestBody)
+	if err := json.Unmarshal(body, requestBody); err != nil {
+		writeBadRequestError(err, w, r)
+		return
+	}
 
-	// launching gorouitne to watch for outputs changes
-	go func(id uint64, isDone <-chan error) {
-		for {
-			select {
-			case <-time.After(time.Second * 5):
-				outputs.Output = outWriter.String()
-				outputs.Errors = errWriter.String()
-				handler.conn.UpdateRecord(
-					id,
-					outputs,
-					db.StatusesTableRecord{ExitCode: -2},
-				)
+	command := newCommandRecord(requestBody, r, body)
+	if handler.replayIdempotent(command, w, r) {
+		return
+	}
 
-				log.Printf("command with id = %d is updated its outputs\n", id)
-			case err := <-isDone:
-				var statuses db.StatusesTableRecord
-				if exitErr, ok := err.(*exec.ExitError); ok || err == nil {
-					if ok {
-						statuses.ExitCode = exitErr.ExitCode()
-					} else {
-						statuses.ExitCode = 0
-					}
+	if err := handler.prepare(requestBody); err != nil {
+		writeBadRequestError(err, w, r)
+		return
+	}
 
-					if statuses.ExitCode == -1 {
-						log.Printf("command with id = %d is interrupted\n", id)
-					} else {
-						log.Printf("command with id = %d is finished\n", id)
-					}
-				} else {
-					statuses.ExitCode = -1
-					log.Printf("command with id = %d isn't started\n", id)
-				}
+	// writing database record
+	id, err := handler.conn.InsertRecord(command, newInputRecord(requestBody))
+	if err != nil {
+		handler.removeWorkspace(requestBody)
+		if err == db.ErrDuplicateIdempotencyKey && handler.replayIdempotent(command, w, r) {
+			return
+		}
 
-				outputs.Output = outWriter.String()
-				outputs.Errors = errWriter.String()
-				handler.conn.UpdateRecord(
-					id,
-					outputs,
-					statuses,
-				)
+		log.Println(err)
+		writeInternalServerError(err, w, r)
+		return
+	}
 
-				// removing cancel function of this command
-				handler.cancelHandler.callAndDelete(id)
+	if command.RunAt != nil {
+		handler.writeLaunchResponse(id, db.StatusesTableRecord{Status: db.StatusScheduled}, w, r)
+		return
+	}
 
-				// returning to end goroutine
-				return
-			}
-		}
-	}(id, isDone)
+	if _, err := handler.start(id, requestBody); err != nil {
+		handler.writeLaunchResponse(id, newStartFailureStatuses(err), w, r)
+		return
+	}
 
-	w.Write([]byte("Launched"))
+	handler.writeLaunchResponse(id, db.StatusesTableRecord{Status: db.StatusRunning}, w, r)
 }
 
 func (handler *GetCommandsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
@@ -184,31 +172,75 @@ func (handler *GetFullCommandHandler) ServeHTTP(w http.ResponseWriter, r *http.R
 		return
 	}
 
+	populateLinks(&fullCommand)
 	json.NewEncoder(w).Encode(&fullCommand)
 }
 
+func (handler *StorageHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	key := r.PathValue("key")
+	if !storage.IsValidKey(key) {
+		writeBadRequestError(fmt.Errorf("invalid object key"), w, r)
+		return
+	}
+
+	reader, err := handler.store.Open(key)
+	if errors.Is(err, fs.ErrNotExist) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		writeInternalServerError(err, w, r)
+		return
+	}
+	defer reader.Close()
+
+	// serving content this way allows clients to use "Range" headers
+	w.Header().Set("Content-Type", "application/octet-stream")
+	http.ServeContent(w, r, key, time.Time{}, reader)
+}
+
 func NewCancelHandler(conn *db.Connection) (*CancelHandler, error) {
 	if err := checkConnection(conn); err != nil {
 		return nil, err
 	}
 
 	h := new(CancelHandler)
-	h.cancelFuncs = make(map[uint64]context.CancelFunc)
+	h.commands = make(map[uint64]*runningCommand)
 	h.conn = conn
 	h.locker = &sync.Mutex{}
 	return h, nil
 }
 
-func NewExecuteHandler(conn *db.Connection, cancelHandler *CancelHandler) (*ExecuteHandler, error) {
+func NewExecuteHandler(
+	conn *db.Connection,
+	cancelHandler *CancelHandler,
+	store storage.Store,
+	outputThreshold int,
+	workspaces *workspace.Manager,
+	instance string,
+) (*ExecuteHandler, error) {
 	if err := checkConnection(conn); err != nil {
 		return nil, err
 	}
 	if err := checkCancelHandler(cancelHandler); err != nil {
 		return nil, err
 	}
+	if err := checkStore(store); err != nil {
+		return nil, err
+	}
+	if err := checkWorkspaces(workspaces); err != nil {
+		return nil, err
+	}
+	if instance == "" {
+		return nil, fmt.Errorf("instance name can't be empty")
+	}
 
 	h := new(ExecuteHandler)
 	h.cancelHandler = cancelHandler
+	h.store = store
+	h.outputThreshold = outputThreshold
+	h.workspaces = workspaces
+	h.instance = instance
 	h.conn = conn
 	return h, nil
 }
@@ -233,35 +265,565 @@ func NewGetFullCommandHandler(conn *db.Connection) (*GetFullCommandHandler, erro
 	return h, nil
 }
 
+func NewStorageHandler(store storage.Store) (*StorageHandler, error) {
+	if err := checkStore(store); err != nil {
+		return nil, err
+	}
+
+	h := new(StorageHandler)
+	h.store = store
+	return h, nil
+}
+
 type RequestBody struct {
 	Workdir string                      `json:"workdir"`
 	Env     []executor.EnvironmentEntry `json:"env"`
 
 	Input   string `json:"input"`
 	Command string `json:"command"`
+
+	// Mode of the command's standard input: with StdinOpen it's kept open
+	// after input, so more data can be written into it.
+	Stdin string `json:"stdin"`
+
+	// Optional name of the group that command belongs to.
+	Group string `json:"group"`
+
+	// Time after which command is killed.
+	TimeoutSeconds float64 `json:"timeout_seconds"`
+
+	// Policy of retrying failed command. Every attempt is stored as
+	// separate command.
+	Retry *retry.Policy `json:"retry"`
+
+	// Criteria of the command's success that define its verdict.
+	Success *verdict.Criteria `json:"success"`
+
+	// Time of the delayed launch: either absolute or relative to the
+	// request.
+	RunAt        *time.Time `json:"run_at"`
+	DelaySeconds float64    `json:"delay_seconds"`
+
+	// IDs of successfully finished commands whose stdout is passed as
+	// input of the command and as values of environment variables.
+	StdinFrom uint64            `json:"stdin_from"`
+	EnvFrom   map[string]uint64 `json:"env_from"`
+
+	// Glob patterns of files collected from working directory into the
+	// storage after command's finish.
+	Artifacts []string `json:"artifacts"`
+
+	// Options of the temporary working directory created for the command.
+	Workspace *WorkspaceOptions `json:"workspace"`
+
+	// path of the created workspace
+	workspace string
+
+	// workspace is shared by attempts and is cleaned up by their parent
+	keepWorkspace bool
+}
+
+// Response body of the launch request.
+type LaunchResponse struct {
+	Id     uint64 `json:"id"`
+	Status string `json:"status"`
+
+	FailureClass   string `json:"failure_class,omitempty"`
+	FailureMessage string `json:"failure_message,omitempty"`
+
+	Links CommandLinks `json:"links"`
+}
+
+// Links to the endpoints of the command.
+type CommandLinks struct {
+	Status string `json:"status"`
+	Output string `json:"output"`
+	Cancel string `json:"cancel"`
+}
+
+func newLaunchResponse(id uint64, statuses db.StatusesTableRecord) LaunchResponse {
+	return LaunchResponse{
+		Id:             id,
+		Status:         statuses.Status,
+		FailureClass:   statuses.FailureClass,
+		FailureMessage: statuses.FailureMessage,
+		Links: CommandLinks{
+			Status: fmt.Sprintf("/api/get_command?id=%d", id),
+			Output: fmt.Sprintf("/api/commands/%d/output", id),
+			Cancel: fmt.Sprintf("/api/cancel?id=%d", id),
+		},
+	}
+}
+
+// Returns copy of the request that can be prepared and launched
+// independently.
+func (requestBody *RequestBody) clone() *RequestBody {
+	clone := *requestBody
+	clone.Env = slices.Clone(requestBody.Env)
+	clone.Artifacts = slices.Clone(requestBody.Artifacts)
+	clone.EnvFrom = maps.Clone(requestBody.EnvFrom)
+	if requestBody.Retry != nil {
+		policy := *requestBody.Retry
+		policy.ExitCodes = slices.Clone(policy.ExitCodes)
+		clone.Retry = &policy
+	}
+	if requestBody.Success != nil {
+		criteria := *requestBody.Success
+		criteria.ExitCodes = slices.Clone(criteria.ExitCodes)
+		criteria.Assertions = slices.Clone(criteria.Assertions)
+		clone.Success = &criteria
+	}
+	if requestBody.Workspace != nil {
+		workspace := *requestBody.Workspace
+		clone.Workspace = &workspace
+	}
+
+	return &clone
 }
 
-func (cancelHandler *CancelHandler) insert(id uint64, cancelFunc context.CancelFunc) {
+func (requestBody *RequestBody) validate() error {
+	if requestBody.Command == "" {
+		return fmt.Errorf("\"command\" parameter must be not empty")
+	}
+
+	if requestBody.Group != "" && !isValidGroupName(requestBody.Group) {
+		return fmt.Errorf("invalid group name \"%s\"", requestBody.Group)
+	}
+
+	if requestBody.RunAt != nil && requestBody.DelaySeconds != 0 {
+		return fmt.Errorf("\"run_at\" and \"delay_seconds\" parameters can't be used together")
+	}
+	if requestBody.DelaySeconds < 0 {
+		return fmt.Errorf("\"delay_seconds\" parameter must be non-negative")
+	}
+
+	switch requestBody.Stdin {
+	case "":
+	case StdinOpen:
+		if requestBody.RunAt != nil || requestBody.DelaySeconds != 0 {
+			return fmt.Errorf("open stdin can't be used with delayed launch")
+		}
+		if requestBody.Retry != nil {
+			return fmt.Errorf("open stdin can't be used with retry policy")
+		}
+	default:
+		return fmt.Errorf("unknown \"stdin\" mode \"%s\"", requestBody.Stdin)
+	}
+
+	if requestBody.TimeoutSeconds < 0 {
+		return fmt.Errorf("\"timeout_seconds\" parameter must be non-negative")
+	}
+	if requestBody.Retry != nil {
+		if err := requestBody.Retry.Validate(); err != nil {
+			return fmt.Errorf("\"retry\": %w", err)
+		}
+	}
+	if requestBody.Success != nil {
+		if err := requestBody.Success.Validate(); err != nil {
+			return fmt.Errorf("\"success\": %w", err)
+		}
+	}
+
+	if requestBody.StdinFrom != 0 && requestBody.Input != "" {
+		return fmt.Errorf("\"stdin_from\" and \"input\" parameters can't be used together")
+	}
+	for key := range requestBody.EnvFrom {
+		if key == "" || strings.Contains(key, "=") {
+			return fmt.Errorf("invalid \"env_from\" variable name \"%s\"", key)
+		}
+	}
+
+	if requestBody.Workspace != nil {
+		if requestBody.Workdir != "" {
+			return fmt.Errorf("\"workspace\" and \"workdir\" parameters can't be used together")
+		}
+		if err := requestBody.Workspace.validate(); err != nil {
+			return err
+		}
+	}
+
+	for _, pattern := range requestBody.Artifacts {
+		if _, err := filepath.Match(pattern, ""); err != nil || !filepath.IsLocal(pattern) {
+			return fmt.Errorf("invalid artifacts pattern \"%s\"", pattern)
+		}
+	}
+
+	return nil
+}
+
+// Creates inputs record of the request with size and SHA-256 of its input.
+func newInputRecord(requestBody *RequestBody) db.InputTableRecord {
+	hash := sha256.Sum256([]byte(requestBody.Input))
+
+	input := db.InputTableRecord{
+		Workdir:     requestBody.Workdir,
+		Input:       requestBody.Input,
+		Env:         requestBody.Env,
+		InputSize:   int64(len(requestBody.Input)),
+		InputSha256: hex.EncodeToString(hash[:]),
+		Artifacts:   requestBody.Artifacts,
+		StdinFrom:   requestBody.StdinFrom,
+		EnvFrom:     requestBody.EnvFrom,
+
+		TimeoutSeconds: requestBody.TimeoutSeconds,
+	}
+
+	if requestBody.Retry != nil {
+		input.Retry, _ = json.Marshal(requestBody.Retry)
+	}
+	if requestBody.Success != nil {
+		input.Success, _ = json.Marshal(requestBody.Success)
+	}
+
+	if requestBody.workspace != "" {
+		input.Workspace = &db.WorkspaceTableRecord{
+			Path:        requestBody.workspace,
+			Template:    requestBody.Workspace.Template,
+			Cleanup:     requestBody.Workspace.Cleanup,
+			RetainHours: requestBody.Workspace.RetainHours,
+		}
+	}
+
+	return input
+}
+
+// Validates request and prepares working directory of the command. Must be
+// called before inserting command into the database.
+func (handler *ExecuteHandler) prepare(requestBody *RequestBody) error {
+	if err := handler.resolve(requestBody); err != nil {
+		return err
+	}
+
+	if requestBody.Workspace != nil {
+		return handler.createWorkspace(requestBody)
+	}
+
+	return nil
+}
+
+// Validates request and resolves references to other commands and its
+// working directory.
+func (handler *ExecuteHandler) resolve(requestBody *RequestBody) error {
+	if err := requestBody.validate(); err != nil {
+		return err
+	}
+
+	if err := handler.resolveOutputs(requestBody); err != nil {
+		return err
+	}
+
+	if requestBody.Workdir != "" {
+		workdir, err := handler.workspaces.ResolveWorkdir(requestBody.Workdir)
+		if err != nil {
+			return err
+		}
+		requestBody.Workdir = workdir
+	}
+
+	return nil
+}
+
+// Checks that request that is launched later can be prepared. Request
+// itself isn't changed.
+func (handler *ExecuteHandler) check(requestBody *RequestBody) error {
+	return handler.resolve(requestBody.clone())
+}
+
+// Prepares, stores and launches command that is launched by the server
+// itself, for example step of the pipeline. Command record may contain
+// links to the pipeline or schedule. Returned ID is zero if command isn't
+// stored.
+func (handler *ExecuteHandler) launch(command db.CommandTableRecord, requestBody *RequestBody) (uint64, <-chan struct{}, error) {
+	if err := handler.prepare(requestBody); err != nil {
+		return 0, nil, err
+	}
+
+	command.Command = requestBody.Command
+	command.Group = requestBody.Group
+
+	id, err := handler.conn.InsertRecord(command, newInputRecord(requestBody))
+	if err != nil {
+		handler.removeWorkspace(requestBody)
+		return 0, nil, err
+	}
+
+	finished, err := handler.start(id, requestBody)
+	return id, finished, err
+}
+
+// Launches command that is already inserted into the database and starts
+// goroutine that watches for its outputs.
+//
+// Returned channel is closed when command is finished. If command can't be
+// started - its *executor.StartError is stored and returned. Stdin is closed
+// afterwards if it's an io.Closer.
+func (handler *ExecuteHandler) run(id uint64, requestBody *RequestBody, stdin io.Reader) (<-chan struct{}, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	// preparing streams
+	outWriter := storage.NewSpillBuffer(handler.store, handler.outputThreshold)
+	errWriter := storage.NewSpillBuffer(handler.store, handler.outputThreshold)
+
+	// launching command
+	env := requestBody.Env
+	if requestBody.workspace != "" {
+		env = append(slices.Clip(env), executor.EnvironmentEntry{Key: WorkspaceEnv, Val: requestBody.workspace})
+	}
+
+	commandExecutor := executor.Executor{Workdir: requestBody.Workdir, Env: env}
+	start := commandExecutor.Start
+	if requestBody.Stdin == StdinOpen {
+		start = commandExecutor.StartWithStdin
+	}
+	process, err := start(
+		ctx,
+		stdin,
+		outWriter,
+		errWriter,
+		requestBody.Command,
+	)
+	if err != nil {
+		cancel()
+		closeInput(stdin)
+		handler.fail(id, requestBody, err)
+		return nil, err
+	}
+	log.Printf("launched command with id = %d", id)
+	insertEvent(handler.conn, id, db.EventStarted, map[string]int{"pid": process.Pid()})
+
+	if err := handler.conn.SetRunning(id, handler.instance); err != nil {
+		log.Println(err)
+	}
+
+	outputs := new(db.OutputsTableRecord)
+	finished := make(chan struct{})
+
+	command := &runningCommand{finished: finished, process: process}
+	if requestBody.TimeoutSeconds > 0 {
+		command.timeout = startTimeout(seconds(requestBody.TimeoutSeconds), cancel)
+	}
+	command.cancel = func() {
+		cancel()
+		// stopped processes can't finish
+		command.continueIfPaused()
+	}
+
+	// populating running commands map
+	handler.cancelHandler.insert(id, command)
+
+	// launching gorouitne to watch for outputs changes
+	go func(id uint64, isDone <-chan error) {
+		defer close(finished)
+
+		var flushedOutputSize, flushedErrorsSize int64
+
+		for {
+			select {
+			case <-time.After(time.Second * 5):
+				snapshotOutputs(outputs, outWriter, errWriter)
+
+				command.locker.Lock()
+				handler.conn.UpdateRecord(
+					id,
+					outputs,
+					db.StatusesTableRecord{Status: command.status(), ExitCode: -2},
+				)
+				command.locker.Unlock()
+
+				// unchanged outputs aren't worth an event
+				if outputs.OutputSize != flushedOutputSize || outputs.ErrorsSize != flushedErrorsSize {
+					flushedOutputSize, flushedErrorsSize = outputs.OutputSize, outputs.ErrorsSize
+					insertEvent(handler.conn, id, db.EventOutputFlushed, map[string]int64{
+						"output_size": outputs.OutputSize,
+						"errors_size": outputs.ErrorsSize,
+					})
+				}
+
+				log.Printf("command with id = %d is updated its outputs\n", id)
+			case err := <-isDone:
+				var statuses db.StatusesTableRecord
+				if exitErr, ok := err.(*exec.ExitError); ok || err == nil {
+					if ok {
+						statuses.ExitCode = exitErr.ExitCode()
+					} else {
+						statuses.ExitCode = 0
+					}
+
+					if statuses.ExitCode == -1 {
+						statuses.Status = db.StatusInterrupted
+						log.Printf("command with id = %d is interrupted\n", id)
+					} else {
+						statuses.Status = db.StatusFinished
+						log.Printf("command with id = %d is finished\n", id)
+					}
+				} else {
+					statuses.Status = db.StatusFailed
+					statuses.ExitCode = -1
+					statuses.FailureClass = executor.ErrorClassIO
+					statuses.FailureMessage = err.Error()
+					log.Printf("command with id = %d is failed: %s\n", id, err)
+				}
+
+				command.timeout.stop()
+				if command.timeout.isExpired() {
+					statuses.Status = db.StatusInterrupted
+					statuses.ExitCode = -1
+					statuses.FailureClass = executor.ErrorClassTimeout
+					statuses.FailureMessage = fmt.Sprintf("command is killed after %s timeout", seconds(requestBody.TimeoutSeconds))
+					log.Printf("command with id = %d is killed by timeout\n", id)
+				}
+
+				closeInput(stdin)
+				if err := closeOutputs(outputs, outWriter, errWriter); err != nil {
+					log.Printf("command with id = %d can't store its outputs: %s\n", id, err)
+				}
+				handler.judge(id, &statuses, outputs, requestBody.Success)
+				if err := handler.collectArtifacts(id, requestBody); err != nil {
+					log.Printf("command with id = %d can't store its artifacts: %s\n", id, err)
+				}
+				if err := handler.cleanupWorkspace(id, requestBody, isSucceeded(statuses)); err != nil {
+					log.Printf("command with id = %d can't clean up its workspace: %s\n", id, err)
+				}
+				handler.conn.UpdateRecord(
+					id,
+					outputs,
+					statuses,
+				)
+				// stored record doesn't refer to partial outputs anymore
+				releaseOutputs(outWriter, errWriter)
+
+				// removing cancel function of this command
+				handler.cancelHandler.callAndDelete(id)
+
+				// returning to end goroutine
+				return
+			}
+		}
+	}(id, process.Done)
+
+	return finished, nil
+}
+
+// Interrupts commands that are left running by the previous process of
+// this server instance. Must be called before any command is launched.
+func (handler *ExecuteHandler) Recover() error {
+	ids, err := handler.conn.InterruptOrphanedCommands(handler.instance, db.StatusesTableRecord{
+		Status:         db.StatusInterrupted,
+		ExitCode:       -1,
+		FailureClass:   executor.ErrorClassRestart,
+		FailureMessage: "server is restarted while command was running",
+		Verdict:        verdict.Failed,
+		VerdictMessage: "command is interrupted",
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		log.Printf("command with id = %d is interrupted by the server's restart\n", id)
+	}
+
+	return nil
+}
+
+// Stores reason why command isn't started.
+func (handler *ExecuteHandler) fail(id uint64, requestBody *RequestBody, err error) {
+	statuses := newStartFailureStatuses(err)
+
+	log.Printf("command with id = %d isn't started: %s\n", id, err)
+
+	if err := handler.cleanupWorkspace(id, requestBody, isSucceeded(statuses)); err != nil {
+		log.Printf("command with id = %d can't clean up its workspace: %s\n", id, err)
+	}
+	if err := handler.conn.UpdateRecord(id, new(db.OutputsTableRecord), statuses); err != nil {
+		log.Println(err)
+	}
+}
+
+// Moves files that match artifacts patterns of the command from its
+// working directory into the storage.
+func (handler *ExecuteHandler) collectArtifacts(id uint64, requestBody *RequestBody) error {
+	if len(requestBody.Artifacts) == 0 {
+		return nil
+	}
+
+	// working directory of the server is never collected
+	workdir := requestBody.Workdir
+	if workdir == "" {
+		log.Printf("artifacts of command with id = %d aren't collected: command has neither workdir nor workspace\n", id)
+		return nil
+	}
+
+	paths, err := workspace.Glob(workdir, requestBody.Artifacts)
+	if err != nil {
+		return err
+	}
+
+	artifacts := make([]db.ArtifactTableRecord, 0, len(paths))
+	for _, path := range paths {
+		object, err := storeFile(handler.store, filepath.Join(workdir, path))
+		if err != nil {
+			return err
+		}
+
+		artifacts = append(artifacts, db.ArtifactTableRecord{
+			Name: path,
+			Key:  object.Key,
+			Size: object.Size,
+		})
+	}
+
+	log.Printf("command with id = %d collected %d artifacts\n", id, len(artifacts))
+	return handler.conn.InsertArtifacts(id, artifacts)
+}
+
+// Struct that holds everything needed to control running command.
+type runningCommand struct {
+	cancel context.CancelFunc
+
+	// closed when command is finished and its results are stored
+	finished <-chan struct{}
+
+	// process of the command, nil if command is run as series of attempts
+	process *executor.Process
+
+	// guards paused state of the command
+	locker sync.Mutex
+	paused bool
+
+	// nil if command isn't limited in time
+	timeout *pausableTimeout
+}
+
+func (cancelHandler *CancelHandler) insert(id uint64, command *runningCommand) {
+	cancelHandler.locker.Lock()
+	defer cancelHandler.locker.Unlock()
+
+	cancelHandler.commands[id] = command
+}
+
+func (cancelHandler *CancelHandler) get(id uint64) (*runningCommand, bool) {
 	cancelHandler.locker.Lock()
 	defer cancelHandler.locker.Unlock()
 
-	cancelHandler.cancelFuncs[id] = cancelFunc
+	command, exists := cancelHandler.commands[id]
+	return command, exists
 }
 
 func (cancelHandler *CancelHandler) delete(id uint64) {
 	cancelHandler.locker.Lock()
 	defer cancelHandler.locker.Unlock()
 
-	delete(cancelHandler.cancelFuncs, id)
+	delete(cancelHandler.commands, id)
 }
 
 func (cancelHandler *CancelHandler) callAndDelete(id uint64) error {
 	cancelHandler.locker.Lock()
 	defer cancelHandler.locker.Unlock()
 
-	if v, exists := cancelHandler.cancelFuncs[id]; exists {
-		v()
-		delete(cancelHandler.cancelFuncs, id)
+	if v, exists := cancelHandler.commands[id]; exists {
+		v.cancel()
+		delete(cancelHandler.commands, id)
 	} else {
 		return fmt.Errorf("no such id")
 	}
@@ -269,6 +831,24 @@ func (cancelHandler *CancelHandler) callAndDelete(id uint64) error {
 	return nil
 }
 
+// Cancels running command or delayed command that isn't launched yet.
+// Requester is stored in the command's history: address of the client or
+// what cancelled the command.
+func (cancelHandler *CancelHandler) cancel(id uint64, requester string) error {
+	if err := cancelHandler.callAndDelete(id); err != nil {
+		cancelled, err := cancelHandler.conn.CancelScheduledCommand(id)
+		if err != nil {
+			return err
+		}
+		if !cancelled {
+			return fmt.Errorf("no such id")
+		}
+	}
+
+	insertEvent(cancelHandler.conn, id, db.EventCancelRequested, map[string]string{"requester": requester})
+	return nil
+}
+
 func checkConnection(conn *db.Connection) error {
 	if conn == nil {
 		return fmt.Errorf("connection can't be nil")
@@ -285,12 +865,206 @@ func checkCancelHandler(cancelHandler *CancelHandler) error {
 	return nil
 }
 
+func checkExecuteHandler(executeHandler *ExecuteHandler) error {
+	if executeHandler == nil {
+		return fmt.Errorf("execute handler can't be nil")
+	}
+
+	return nil
+}
+
+func checkStore(store storage.Store) error {
+	if store == nil {
+		return fmt.Errorf("store can't be nil")
+	}
+
+	return nil
+}
+
+func checkWorkspaces(workspaces *workspace.Manager) error {
+	if workspaces == nil {
+		return fmt.Errorf("workspaces manager can't be nil")
+	}
+
+	return nil
+}
+
+// Populates outputs with current contents of the streams. Streams that
+// are moved into the storage are represented by their sizes and partial
+// names, so they can be read while command is running.
+func snapshotOutputs(outputs *db.OutputsTableRecord, outWriter, errWriter *storage.SpillBuffer) {
+	output, outputPartial, outputSize := outWriter.Snapshot()
+	errOutput, errorsPartial, errorsSize := errWriter.Snapshot()
+
+	outputs.Output, outputs.OutputPartial, outputs.OutputSize = output, outputPartial, outputSize
+	outputs.Errors, outputs.ErrorsPartial, outputs.ErrorsSize = errOutput, errorsPartial, errorsSize
+}
+
+// Finishes streams and populates outputs with their final contents or
+// keys of the stored objects. Stored objects stay readable by their
+// partial names until releaseOutputs.
+func closeOutputs(outputs *db.OutputsTableRecord, outWriter, errWriter *storage.SpillBuffer) error {
+	outputs.OutputPartial, outputs.ErrorsPartial = "", ""
+
+	output, outputObject, outputErr := outWriter.Close()
+	outputs.Output, outputs.OutputSize = output, int64(len(output))
+	if outputObject != nil {
+		outputs.OutputKey, outputs.OutputSize = outputObject.Key, outputObject.Size
+	}
+
+	errOutput, errorsObject, errorsErr := errWriter.Close()
+	outputs.Errors, outputs.ErrorsSize = errOutput, int64(len(errOutput))
+	if errorsObject != nil {
+		outputs.ErrorsKey, outputs.ErrorsSize = errorsObject.Key, errorsObject.Size
+	}
+
+	if outputErr != nil {
+		return outputErr
+	}
+	return errorsErr
+}
+
+// Removes partial names of the streams that are moved into the storage.
+func releaseOutputs(outWriter, errWriter *storage.SpillBuffer) {
+	for _, writer := range []*storage.SpillBuffer{outWriter, errWriter} {
+		if err := writer.Release(); err != nil {
+			log.Println(err)
+		}
+	}
+}
+
+// Copies file into the store.
+func storeFile(store storage.Store, path string) (storage.Object, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return storage.Object{}, err
+	}
+	defer file.Close()
+
+	writer, err := store.Create()
+	if err != nil {
+		return storage.Object{}, err
+	}
+
+	if _, err := io.Copy(writer, file); err != nil {
+		writer.Abort()
+		return storage.Object{}, err
+	}
+
+	object, err := writer.Commit()
+	if err != nil {
+		return object, err
+	}
+
+	return object, writer.Release()
+}
+
+// Populates download links of the stored outputs and artifacts.
+func populateLinks(record *db.FullCommandRecord) {
+	if record.Outputs.OutputKey != "" {
+		record.Outputs.OutputUrl = storageUrl(record.Outputs.OutputKey)
+	}
+	if record.Outputs.ErrorsKey != "" {
+		record.Outputs.ErrorsUrl = storageUrl(record.Outputs.ErrorsKey)
+	}
+
+	// incomplete outputs are served by the output endpoint
+	if record.Outputs.OutputPartial != "" {
+		record.Outputs.OutputUrl = outputUrl(record.Command.Id, "stdout")
+	}
+	if record.Outputs.ErrorsPartial != "" {
+		record.Outputs.ErrorsUrl = outputUrl(record.Command.Id, "stderr")
+	}
+	for i := range record.Artifacts {
+		record.Artifacts[i].Url = artifactUrl(record.Command.Id, record.Artifacts[i].Name)
+	}
+}
+
+func storageUrl(key string) string {
+	return fmt.Sprintf("/api/storage/%s", key)
+}
+
+func outputUrl(id uint64, stream string) string {
+	return fmt.Sprintf("/api/commands/%d/output/raw?stream=%s", id, stream)
+}
+
 func writeInternalServerError(err error, w http.ResponseWriter, _ *http.Request) {
 	w.WriteHeader(http.StatusInternalServerError)
 	w.Write([]byte(fmt.Sprintf("500 Internal Server Error: %s", err.Error())))
 }
 
+// Returns statuses of the command that isn't started because of err.
+func newStartFailureStatuses(err error) db.StatusesTableRecord {
+	statuses := db.StatusesTableRecord{
+		Status:         db.StatusFailed,
+		ExitCode:       -1,
+		FailureClass:   executor.ErrorClassStart,
+		FailureMessage: err.Error(),
+		Verdict:        verdict.Failed,
+		VerdictMessage: "command isn't started",
+	}
+
+	var startErr *executor.StartError
+	if errors.As(err, &startErr) {
+		statuses.FailureClass = startErr.Class
+		statuses.FailureMessage = startErr.Err.Error()
+	}
+
+	return statuses
+}
+
+// Responds to launch request with "201 Created" and links to the created
+// command. If command isn't started - responds with
+// "422 Unprocessable Entity" and the reason.
+//
+// If request has "wait=true" query parameter - waits for command's finish
+// up to "timeout" seconds and responds same as WaitHandler.
+func (handler *ExecuteHandler) writeLaunchResponse(
+	id uint64,
+	statuses db.StatusesTableRecord,
+	w http.ResponseWriter,
+	r *http.Request,
+) {
+	urlValues := r.URL.Query()
+	if urlValues.Get("wait") == "true" && statuses.Status != db.StatusFailed {
+		// request parameters are validated before launch
+		timeout, _ := parseWaitTimeout(urlValues.Get("timeout"))
+
+		record, err := waitForCommand(handler.conn, handler.cancelHandler, id, timeout)
+		if err != nil {
+			writeInternalServerError(err, w, r)
+			return
+		}
+
+		w.Header().Set("Location", newLaunchResponse(id, statuses).Links.Status)
+		writeWaitResponse(record, w, r)
+		return
+	}
+
+	response := newLaunchResponse(id, statuses)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", response.Links.Status)
+	if statuses.Status == db.StatusFailed {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+	} else {
+		w.WriteHeader(http.StatusCreated)
+	}
+
+	json.NewEncoder(w).Encode(&response)
+}
+
+func writeConflictError(err error, w http.ResponseWriter, _ *http.Request) {
+	w.WriteHeader(http.StatusConflict)
+	w.Write([]byte(fmt.Sprintf("409 Conflict: %s", err.Error())))
+}
+
 func writeBadRequestError(err error, w http.ResponseWriter, _ *http.Request) {
 	w.WriteHeader(http.StatusBadRequest)
 	w.Write([]byte(fmt.Sprintf("400 Bad Request: %s", err.Error())))
 }
+
+// Converts fractional amount of seconds into duration.
+func seconds(value float64) time.Duration {
+	return time.Duration(value * float64(time.Second))
+}
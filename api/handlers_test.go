@@ -0,0 +1,13 @@
+package api
+
+import "testing"
+
+func TestCollectArtifactsWithoutWorkdir(t *testing.T) {
+	// handler has neither storage nor connection, so any collection fails
+	handler := new(ExecuteHandler)
+
+	requestBody := &RequestBody{Command: "echo amogus", Artifacts: []string{"*"}}
+	if err := handler.collectArtifacts(1, requestBody); err != nil {
+		t.Fatalf("artifacts without workdir must be skipped, got \"%s\"", err)
+	}
+}
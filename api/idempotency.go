@@ -0,0 +1,87 @@
+package api
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"db"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Header that allows clients to retry launch requests safely
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// Creates commands record of the request. Idempotency key is taken from
+// the request's header and request hash is calculated from its raw body.
+func newCommandRecord(requestBody *RequestBody, r *http.Request, rawRequest []byte) db.CommandTableRecord {
+	command := db.CommandTableRecord{
+		Command:        requestBody.Command,
+		Group:          requestBody.Group,
+		IdempotencyKey: r.Header.Get(IdempotencyKeyHeader),
+		RunAt:          requestBody.launchTime(time.Now()),
+	}
+
+	if command.IdempotencyKey != "" {
+		hash := sha256.Sum256(rawRequest)
+		command.RequestSha256 = hex.EncodeToString(hash[:])
+	}
+
+	return command
+}
+
+// Responds to the request with already used idempotency key. If request is
+// the same - responds with the command launched by the original request,
+// otherwise responds with "409 Conflict".
+//
+// Returns false if idempotency key isn't used yet.
+func (handler *ExecuteHandler) replayIdempotent(command db.CommandTableRecord, w http.ResponseWriter, r *http.Request) bool {
+	if command.IdempotencyKey == "" {
+		return false
+	}
+
+	id, requestSha256, err := handler.conn.GetIdempotentCommand(command.IdempotencyKey)
+	if err == sql.ErrNoRows {
+		return false
+	}
+	if err != nil {
+		writeInternalServerError(err, w, r)
+		return true
+	}
+
+	if requestSha256 != command.RequestSha256 {
+		writeConflictError(
+			fmt.Errorf("idempotency key is already used by command with id = %d with different request", id),
+			w,
+			r,
+		)
+		return true
+	}
+
+	urlValues := r.URL.Query()
+	var timeout time.Duration
+	if urlValues.Get("wait") == "true" {
+		// request parameters are validated before launch
+		timeout, _ = parseWaitTimeout(urlValues.Get("timeout"))
+	}
+
+	record, err := waitForCommand(handler.conn, handler.cancelHandler, id, timeout)
+	if err != nil {
+		writeInternalServerError(err, w, r)
+		return true
+	}
+
+	response := newLaunchResponse(id, record.Statuses)
+	w.Header().Set("Location", response.Links.Status)
+
+	if urlValues.Get("wait") == "true" {
+		writeWaitResponse(record, w, r)
+		return true
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(&response)
+	return true
+}
@@ -0,0 +1,245 @@
+package api
+
+import (
+	"bytes"
+	"database/sql"
+	"db"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"storage"
+	"strconv"
+	"time"
+	"unicode/utf8"
+)
+
+// Amount of the first bytes of the stream that define its content type
+const sniffSize = 8192
+
+type OutputHandler struct {
+	store storage.Store
+
+	// raw handler serves streams as binary data
+	raw bool
+
+	conn *db.Connection
+}
+
+// Serves one of the command's streams. Part of the stream can be selected
+// with "offset" and "limit" query parameters, "tail" parameter or with
+// "Range" header.
+func (handler *OutputHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+	if err != nil {
+		writeBadRequestError(err, w, r)
+		return
+	}
+
+	urlValues := r.URL.Query()
+	stream := urlValues.Get("stream")
+	if stream == "" {
+		stream = "stdout"
+	}
+	if stream != "stdout" && stream != "stderr" {
+		writeBadRequestError(fmt.Errorf("\"stream\" parameter must be \"stdout\" or \"stderr\""), w, r)
+		return
+	}
+
+	reader, size, err := handler.openStream(id, stream)
+	if err == sql.ErrNoRows {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		writeInternalServerError(err, w, r)
+		return
+	}
+	defer reader.Close()
+
+	// "Range" header has precedence over query parameters
+	if r.Header.Get("Range") == "" {
+		start, end, err := parseOutputWindow(reader, size, urlValues)
+		if err != nil {
+			writeBadRequestError(err, w, r)
+			return
+		}
+
+		if start != 0 || end != size {
+			r.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", start, end-1))
+		}
+	}
+
+	if handler.raw {
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set(
+			"Content-Disposition",
+			fmt.Sprintf("attachment; filename=\"%d.%s\"", id, stream),
+		)
+	} else {
+		contentType, err := sniffContentType(reader, size)
+		if err != nil {
+			writeInternalServerError(err, w, r)
+			return
+		}
+		w.Header().Set("Content-Type", contentType)
+	}
+	http.ServeContent(w, r, "", time.Time{}, reader)
+}
+
+func NewOutputHandler(conn *db.Connection, store storage.Store) (*OutputHandler, error) {
+	if err := checkConnection(conn); err != nil {
+		return nil, err
+	}
+	if err := checkStore(store); err != nil {
+		return nil, err
+	}
+
+	h := new(OutputHandler)
+	h.store = store
+	h.conn = conn
+	return h, nil
+}
+
+// Creates OutputHandler that serves streams as "application/octet-stream".
+func NewRawOutputHandler(conn *db.Connection, store storage.Store) (*OutputHandler, error) {
+	h, err := NewOutputHandler(conn, store)
+	if err != nil {
+		return nil, err
+	}
+
+	h.raw = true
+	return h, nil
+}
+
+// Opens stream of the command and returns its current size.
+func (handler *OutputHandler) openStream(id uint64, stream string) (storage.Reader, int64, error) {
+	outputs, err := handler.conn.GetOutputsById(id)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return openOutput(handler.store, outputs, stream)
+}
+
+// Opens stream of the outputs record. Stream is kept either in the
+// database, in the storage or, while command is running, in the partial
+// object of the storage. Partial object stays readable until the record
+// of finished command is stored.
+func openOutput(store storage.Store, outputs db.OutputsTableRecord, stream string) (storage.Reader, int64, error) {
+	var err error
+
+	content, key, partial := outputs.Output, outputs.OutputKey, outputs.OutputPartial
+	if stream == "stderr" {
+		content, key, partial = outputs.Errors, outputs.ErrorsKey, outputs.ErrorsPartial
+	}
+
+	var reader storage.Reader
+	switch {
+	case key != "":
+		reader, err = store.Open(key)
+	case partial != "":
+		reader, err = store.OpenPartial(partial)
+	default:
+		return nopCloser{bytes.NewReader(content)}, int64(len(content)), nil
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// partial object keeps growing, so window is calculated for its
+	// current size
+	size, err := reader.Seek(0, io.SeekEnd)
+	if err != nil {
+		reader.Close()
+		return nil, 0, err
+	}
+
+	return reader, size, nil
+}
+
+// Returns bytes window of the content selected with "offset", "limit" and
+// "tail" query parameters. End of the window is exclusive.
+func parseOutputWindow(reader io.ReaderAt, size int64, urlValues url.Values) (int64, int64, error) {
+	start, end := int64(0), size
+
+	if stringTail := urlValues.Get("tail"); stringTail != "" {
+		tail, err := strconv.ParseUint(stringTail, 10, 31)
+		if err != nil {
+			return 0, 0, err
+		}
+
+		start, err = storage.TailOffset(reader, size, int(tail))
+		if err != nil {
+			return 0, 0, err
+		}
+	}
+
+	if stringOffset := urlValues.Get("offset"); stringOffset != "" {
+		offset, err := strconv.ParseInt(stringOffset, 10, 64)
+		if err != nil {
+			return 0, 0, err
+		}
+		if offset < 0 {
+			return 0, 0, fmt.Errorf("\"offset\" parameter must not be negative")
+		}
+
+		start += offset
+	}
+
+	if stringLimit := urlValues.Get("limit"); stringLimit != "" {
+		limit, err := strconv.ParseInt(stringLimit, 10, 64)
+		if err != nil {
+			return 0, 0, err
+		}
+		if limit <= 0 {
+			return 0, 0, fmt.Errorf("\"limit\" parameter must be positive")
+		}
+
+		end = min(start+limit, size)
+	}
+
+	if start == 0 && end == size {
+		return start, end, nil
+	}
+	if start >= end {
+		return 0, 0, fmt.Errorf("requested part of the output is empty")
+	}
+
+	return start, end, nil
+}
+
+// Returns content type of the stream by its first bytes: plain text if
+// they are valid UTF-8 without NUL bytes and binary data otherwise.
+func sniffContentType(reader io.ReaderAt, size int64) (string, error) {
+	head := make([]byte, min(size, sniffSize))
+	if _, err := reader.ReadAt(head, 0); err != nil && err != io.EOF {
+		return "", err
+	}
+
+	// last rune can be cut by the end of the sniffed part
+	if int64(len(head)) < size {
+		start := len(head) - 1
+		for start > 0 && len(head)-start < utf8.UTFMax && !utf8.RuneStart(head[start]) {
+			start--
+		}
+		if !utf8.FullRune(head[start:]) {
+			head = head[:start]
+		}
+	}
+
+	if !utf8.Valid(head) || slices.Contains(head, 0) {
+		return "application/octet-stream", nil
+	}
+
+	return "text/plain; charset=utf-8", nil
+}
+
+type nopCloser struct {
+	*bytes.Reader
+}
+
+func (nopCloser) Close() error {
+	return nil
+}
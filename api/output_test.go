@@ -0,0 +1,148 @@
+package api
+
+import (
+	"db"
+	"errors"
+	"io"
+	"io/fs"
+	"net/url"
+	"storage"
+	"strings"
+	"testing"
+)
+
+func TestParseOutputWindow(t *testing.T) {
+	content := "amogus\nsus\nimpostor\n"
+	size := int64(len(content))
+
+	cases := []struct {
+		query string
+		start int64
+		end   int64
+		fails bool
+	}{
+		{query: "", start: 0, end: size},
+		{query: "offset=7", start: 7, end: size},
+		{query: "limit=6", start: 0, end: 6},
+		{query: "offset=7&limit=3", start: 7, end: 10},
+		{query: "offset=7&limit=100", start: 7, end: size},
+		{query: "tail=1", start: 11, end: size},
+		{query: "tail=2", start: 7, end: size},
+		{query: "tail=100", start: 0, end: size},
+		{query: "tail=0", start: size, end: size, fails: true},
+		{query: "tail=2&limit=3", start: 7, end: 10},
+		{query: "tail=2&offset=4", start: 11, end: size},
+		{query: "offset=0&limit=100", start: 0, end: size},
+		{query: "offset=100", fails: true},
+		{query: "offset=-1", fails: true},
+		{query: "limit=0", fails: true},
+		{query: "limit=-5", fails: true},
+		{query: "offset=sus", fails: true},
+		{query: "limit=sus", fails: true},
+		{query: "tail=-1", fails: true},
+		{query: "tail=sus", fails: true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.query, func(t *testing.T) {
+			urlValues, err := url.ParseQuery(c.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			start, end, err := parseOutputWindow(strings.NewReader(content), size, urlValues)
+			if c.fails {
+				if err == nil {
+					t.Fatalf("window must be rejected, got [%d, %d)", start, end)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if start != c.start || end != c.end {
+				t.Fatalf("window must be [%d, %d), got [%d, %d)", c.start, c.end, start, end)
+			}
+		})
+	}
+}
+
+func TestOpenOutputOfFinishingCommand(t *testing.T) {
+	store, err := storage.NewLocalStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	content := strings.Repeat("amogus\n", 10)
+	outWriter := storage.NewSpillBuffer(store, 16)
+	errWriter := storage.NewSpillBuffer(store, 16)
+	io.WriteString(outWriter, content)
+
+	// record that is stored while command is running
+	running := db.OutputsTableRecord{}
+	snapshotOutputs(&running, outWriter, errWriter)
+	if running.OutputPartial == "" {
+		t.Fatalf("spilled output must have partial name")
+	}
+
+	finished := db.OutputsTableRecord{}
+	if err := closeOutputs(&finished, outWriter, errWriter); err != nil {
+		t.Fatal(err)
+	}
+
+	// finished record isn't stored yet
+	checkOutput(t, store, running, content)
+
+	releaseOutputs(outWriter, errWriter)
+
+	checkOutput(t, store, finished, content)
+	if _, _, err := openOutput(store, running, "stdout"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("released partial output must not exist, got \"%v\"", err)
+	}
+}
+
+func checkOutput(t *testing.T, store storage.Store, outputs db.OutputsTableRecord, expected string) {
+	t.Helper()
+
+	reader, size, err := openOutput(store, outputs, "stdout")
+	if err != nil {
+		t.Fatalf("output must be opened, got \"%s\"", err)
+	}
+	defer reader.Close()
+
+	content, _ := io.ReadAll(io.NewSectionReader(reader, 0, size))
+	if string(content) != expected || size != int64(len(expected)) {
+		t.Fatalf("output must be \"%s\", got \"%s\"", expected, content)
+	}
+}
+
+func TestSniffContentType(t *testing.T) {
+	text := "text/plain; charset=utf-8"
+	binary := "application/octet-stream"
+
+	cases := []struct {
+		name     string
+		content  string
+		expected string
+	}{
+		{name: "empty", content: "", expected: text},
+		{name: "text", content: "amogus\nsus\n", expected: text},
+		{name: "unicode", content: "абоба\n", expected: text},
+		{name: "invalid", content: "amogus\xff\xfe", expected: binary},
+		{name: "nul", content: "amogus\x00sus", expected: binary},
+		{name: "cut rune", content: strings.Repeat("a", sniffSize-1) + "б", expected: text},
+		{name: "binary tail", content: strings.Repeat("a", sniffSize) + "\xff", expected: text},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			contentType, err := sniffContentType(strings.NewReader(c.content), int64(len(c.content)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if contentType != c.expected {
+				t.Fatalf("content type must be \"%s\", got \"%s\"", c.expected, contentType)
+			}
+		})
+	}
+}
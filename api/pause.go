@@ -0,0 +1,222 @@
+package api
+
+import (
+	"context"
+	"db"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+type PauseHandler struct {
+	cancelHandler *CancelHandler
+
+	conn *db.Connection
+}
+
+type ResumeHandler struct {
+	cancelHandler *CancelHandler
+
+	conn *db.Connection
+}
+
+// Stops processes of the running command until it's resumed.
+func (handler *PauseHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	servePauseRequest(handler.cancelHandler, handler.conn, true, w, r)
+}
+
+// Continues processes of the paused command.
+func (handler *ResumeHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	servePauseRequest(handler.cancelHandler, handler.conn, false, w, r)
+}
+
+func NewPauseHandler(conn *db.Connection, cancelHandler *CancelHandler) (*PauseHandler, error) {
+	if err := checkConnection(conn); err != nil {
+		return nil, err
+	}
+	if err := checkCancelHandler(cancelHandler); err != nil {
+		return nil, err
+	}
+
+	h := new(PauseHandler)
+	h.cancelHandler = cancelHandler
+	h.conn = conn
+	return h, nil
+}
+
+func NewResumeHandler(conn *db.Connection, cancelHandler *CancelHandler) (*ResumeHandler, error) {
+	if err := checkConnection(conn); err != nil {
+		return nil, err
+	}
+	if err := checkCancelHandler(cancelHandler); err != nil {
+		return nil, err
+	}
+
+	h := new(ResumeHandler)
+	h.cancelHandler = cancelHandler
+	h.conn = conn
+	return h, nil
+}
+
+// Response body of the pause and resume requests.
+type PauseResponse struct {
+	Id     uint64 `json:"id"`
+	Status string `json:"status"`
+}
+
+// Pauses or resumes the command and stores it in the command's history.
+func servePauseRequest(
+	cancelHandler *CancelHandler,
+	conn *db.Connection,
+	pause bool,
+	w http.ResponseWriter,
+	r *http.Request,
+) {
+	id, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+	if err != nil {
+		writeBadRequestError(err, w, r)
+		return
+	}
+
+	command, exists := cancelHandler.get(id)
+	if !exists {
+		http.NotFound(w, r)
+		return
+	}
+	if command.process == nil {
+		writeConflictError(fmt.Errorf("command with id = %d is run as attempts, pause its current attempt", id), w, r)
+		return
+	}
+
+	event := db.EventPaused
+	if !pause {
+		event = db.EventResumed
+	}
+
+	if err := command.setPaused(id, conn, pause); err != nil {
+		writeConflictError(err, w, r)
+		return
+	}
+	log.Printf("command with id = %d is %s\n", id, event)
+
+	insertEvent(conn, id, event, requestEventData{RemoteAddr: r.RemoteAddr})
+
+	json.NewEncoder(w).Encode(PauseResponse{Id: id, Status: command.status()})
+}
+
+// Stops or continues process group of the command, its timeout and
+// changes its lifecycle status.
+func (command *runningCommand) setPaused(id uint64, conn *db.Connection, pause bool) error {
+	command.locker.Lock()
+	defer command.locker.Unlock()
+
+	if command.paused == pause {
+		return fmt.Errorf("command with id = %d is already %s", id, command.status())
+	}
+
+	signal, current, status := syscall.SIGSTOP, db.StatusRunning, db.StatusPaused
+	if !pause {
+		signal, current, status = syscall.SIGCONT, db.StatusPaused, db.StatusRunning
+	}
+
+	if err := command.process.Signal(signal, true); err != nil {
+		return fmt.Errorf("command with id = %d isn't running: %w", id, err)
+	}
+	if pause {
+		command.timeout.pause()
+	} else {
+		command.timeout.resume()
+	}
+	command.paused = pause
+
+	// finished command keeps its status
+	if _, err := conn.SwapStatus(id, current, status); err != nil {
+		log.Println(err)
+	}
+
+	return nil
+}
+
+// Continues stopped processes of the cancelled command.
+func (command *runningCommand) continueIfPaused() {
+	command.locker.Lock()
+	defer command.locker.Unlock()
+
+	if command.paused {
+		command.process.Signal(syscall.SIGCONT, true)
+	}
+}
+
+// Returns lifecycle status of the running command. Must be called with
+// locked locker.
+func (command *runningCommand) status() string {
+	if command.paused {
+		return db.StatusPaused
+	}
+
+	return db.StatusRunning
+}
+
+// Timeout of the command that doesn't count time while command is paused.
+// Methods of nil timeout do nothing.
+type pausableTimeout struct {
+	locker    sync.Mutex
+	timer     *time.Timer
+	remaining time.Duration
+	startedAt time.Time
+
+	expired atomic.Bool
+}
+
+// Starts timeout that cancels command after duration.
+func startTimeout(duration time.Duration, cancel context.CancelFunc) *pausableTimeout {
+	timeout := &pausableTimeout{remaining: duration, startedAt: time.Now()}
+	timeout.timer = time.AfterFunc(duration, func() {
+		timeout.expired.Store(true)
+		cancel()
+	})
+
+	return timeout
+}
+
+func (timeout *pausableTimeout) pause() {
+	if timeout == nil {
+		return
+	}
+
+	timeout.locker.Lock()
+	defer timeout.locker.Unlock()
+
+	if timeout.timer.Stop() {
+		timeout.remaining -= time.Since(timeout.startedAt)
+	}
+}
+
+func (timeout *pausableTimeout) resume() {
+	if timeout == nil || timeout.expired.Load() {
+		return
+	}
+
+	timeout.locker.Lock()
+	defer timeout.locker.Unlock()
+
+	timeout.startedAt = time.Now()
+	timeout.timer.Reset(timeout.remaining)
+}
+
+func (timeout *pausableTimeout) stop() {
+	if timeout != nil {
+		timeout.timer.Stop()
+	}
+}
+
+// Reports whether command is cancelled by its timeout.
+func (timeout *pausableTimeout) isExpired() bool {
+	return timeout != nil && timeout.expired.Load()
+}
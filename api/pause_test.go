@@ -0,0 +1,76 @@
+package api
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestPausableTimeoutExpires(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	timeout := startTimeout(time.Millisecond*50, cancel)
+
+	select {
+	case <-ctx.Done():
+	case <-time.After(time.Second):
+		t.Fatalf("command must be cancelled by its timeout")
+	}
+	if !timeout.isExpired() {
+		t.Fatalf("timeout must be expired")
+	}
+}
+
+func TestPausableTimeoutPause(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	timeout := startTimeout(time.Millisecond*200, cancel)
+
+	time.Sleep(time.Millisecond * 150)
+	timeout.pause()
+
+	// paused timeout doesn't expire after its deadline
+	select {
+	case <-ctx.Done():
+		t.Fatalf("paused timeout must not expire")
+	case <-time.After(time.Millisecond * 200):
+	}
+
+	resumedAt := time.Now()
+	timeout.resume()
+
+	select {
+	case <-ctx.Done():
+	case <-time.After(time.Second):
+		t.Fatalf("resumed timeout must expire")
+	}
+	if !timeout.isExpired() {
+		t.Fatalf("timeout must be expired")
+	}
+	// only remaining time is waited after resume
+	if elapsed := time.Since(resumedAt); elapsed >= time.Millisecond*150 {
+		t.Fatalf("timeout must expire in remaining 50ms after resume, got %s", elapsed)
+	}
+}
+
+func TestPausableTimeoutStop(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	timeout := startTimeout(time.Millisecond*50, cancel)
+	timeout.stop()
+
+	select {
+	case <-ctx.Done():
+		t.Fatalf("stopped timeout must not expire")
+	case <-time.After(time.Millisecond * 100):
+	}
+	if timeout.isExpired() {
+		t.Fatalf("stopped timeout must not be expired")
+	}
+
+	// commands without timeout have nil timeout
+	var none *pausableTimeout
+	none.pause()
+	none.resume()
+	none.stop()
+	if none.isExpired() {
+		t.Fatalf("nil timeout must not be expired")
+	}
+}
@@ -0,0 +1,578 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"db"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"pipeline"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Interval of checking whether cancellation of the running pipeline is
+// requested through another server instance
+const pipelinePollInterval = time.Second
+
+type PipelineHandler struct {
+	executeHandler *ExecuteHandler
+
+	// cancel functions of the pipelines executed by this server
+	pipelines map[uint64]context.CancelFunc
+	locker    sync.Locker
+
+	conn *db.Connection
+}
+
+type GetPipelineHandler struct {
+	conn *db.Connection
+}
+
+type CancelPipelineHandler struct {
+	pipelineHandler *PipelineHandler
+}
+
+// Creates pipeline and starts its execution. Steps are launched as soon as
+// their dependencies are done and their conditions are met.
+func (handler *PipelineHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	pipelineBody := new(PipelineRequestBody)
+	if err := json.NewDecoder(r.Body).Decode(pipelineBody); err != nil {
+		writeBadRequestError(err, w, r)
+		return
+	}
+
+	steps, records, err := pipelineBody.steps()
+	if err != nil {
+		writeBadRequestError(err, w, r)
+		return
+	}
+
+	// steps are launched the same way as by "/api/launch", outputs of
+	// other steps are checked when they are passed
+	for _, step := range steps {
+		if err := handler.executeHandler.check(step.requestBody); err != nil {
+			writeBadRequestError(fmt.Errorf("step \"%s\": %w", step.Name, err), w, r)
+			return
+		}
+	}
+
+	pipelineId, err := handler.conn.InsertPipeline(handler.executeHandler.instance, records)
+	if err != nil {
+		log.Println(err)
+		writeInternalServerError(err, w, r)
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	handler.insert(pipelineId, cancel)
+	go handler.execute(ctx, pipelineId, steps)
+	log.Printf("launched pipeline with id = %d of %d steps\n", pipelineId, len(steps))
+
+	response := PipelineResponse{
+		Id:     pipelineId,
+		Status: pipeline.StatusRunning,
+		Links:  newPipelineLinks(pipelineId),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", response.Links.Status)
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(&response)
+}
+
+func (handler *GetPipelineHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	pipelineId, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+	if err != nil {
+		writeBadRequestError(err, w, r)
+		return
+	}
+
+	record, err := handler.conn.GetPipeline(pipelineId)
+	if err == sql.ErrNoRows {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		writeInternalServerError(err, w, r)
+		return
+	}
+
+	json.NewEncoder(w).Encode(&record)
+}
+
+// Cancels running steps of the pipeline. Pending steps are not launched
+// anymore. Pipeline that is executed by another server instance is
+// cancelled by that instance.
+func (handler *CancelPipelineHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	pipelineId, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+	if err != nil {
+		writeBadRequestError(err, w, r)
+		return
+	}
+
+	if err := handler.pipelineHandler.cancel(pipelineId); err == nil {
+		w.Write([]byte("Stopped"))
+		return
+	}
+
+	conn := handler.pipelineHandler.conn
+	requested, err := conn.RequestPipelineCancel(pipelineId, pipeline.StatusRunning)
+	if err != nil {
+		writeInternalServerError(err, w, r)
+		return
+	}
+	if requested {
+		w.WriteHeader(http.StatusAccepted)
+		w.Write([]byte("Cancellation is requested"))
+		return
+	}
+
+	record, err := conn.GetPipeline(pipelineId)
+	if err == sql.ErrNoRows {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		writeInternalServerError(err, w, r)
+		return
+	}
+
+	writeConflictError(fmt.Errorf("pipeline with id = %d is already %s", pipelineId, record.Status), w, r)
+}
+
+func NewPipelineHandler(conn *db.Connection, executeHandler *ExecuteHandler) (*PipelineHandler, error) {
+	if err := checkConnection(conn); err != nil {
+		return nil, err
+	}
+	if err := checkExecuteHandler(executeHandler); err != nil {
+		return nil, err
+	}
+
+	h := new(PipelineHandler)
+	h.executeHandler = executeHandler
+	h.pipelines = make(map[uint64]context.CancelFunc)
+	h.locker = new(sync.Mutex)
+	h.conn = conn
+	return h, nil
+}
+
+func NewGetPipelineHandler(conn *db.Connection) (*GetPipelineHandler, error) {
+	if err := checkConnection(conn); err != nil {
+		return nil, err
+	}
+
+	h := new(GetPipelineHandler)
+	h.conn = conn
+	return h, nil
+}
+
+func NewCancelPipelineHandler(pipelineHandler *PipelineHandler) (*CancelPipelineHandler, error) {
+	if pipelineHandler == nil {
+		return nil, fmt.Errorf("pipeline handler can't be nil")
+	}
+
+	h := new(CancelPipelineHandler)
+	h.pipelineHandler = pipelineHandler
+	return h, nil
+}
+
+func (handler *PipelineHandler) insert(pipelineId uint64, cancel context.CancelFunc) {
+	handler.locker.Lock()
+	defer handler.locker.Unlock()
+
+	handler.pipelines[pipelineId] = cancel
+}
+
+func (handler *PipelineHandler) delete(pipelineId uint64) {
+	handler.locker.Lock()
+	defer handler.locker.Unlock()
+
+	delete(handler.pipelines, pipelineId)
+}
+
+func (handler *PipelineHandler) cancel(pipelineId uint64) error {
+	handler.locker.Lock()
+	defer handler.locker.Unlock()
+
+	cancel, exists := handler.pipelines[pipelineId]
+	if !exists {
+		return fmt.Errorf("pipeline with id = %d isn't running", pipelineId)
+	}
+
+	cancel()
+	return nil
+}
+
+// Executes steps of the pipeline until all of them are done. After
+// cancellation running steps are cancelled and pending ones are not
+// launched.
+func (handler *PipelineHandler) execute(ctx context.Context, pipelineId uint64, steps []*pipelineStep) {
+	defer handler.delete(pipelineId)
+
+	statuses := make(map[string]string, len(steps))
+	for _, step := range steps {
+		statuses[step.Name] = pipeline.StatusPending
+	}
+
+	byName := make(map[string]*pipelineStep, len(steps))
+	for _, step := range steps {
+		byName[step.Name] = step
+	}
+
+	results := make(chan stepResult)
+	running := make(map[string]uint64)
+	cancelled := ctx.Done()
+
+	for {
+		// skipping a step may let other steps to be decided, so steps are
+		// checked until nothing changes
+		for changed := true; changed; {
+			changed = false
+
+			for _, step := range steps {
+				if statuses[step.Name] != pipeline.StatusPending {
+					continue
+				}
+
+				status := pipeline.StatusCancelled
+				if ctx.Err() == nil {
+					status = pipeline.Next(step.Step, statuses)
+				}
+
+				switch status {
+				case pipeline.StatusPending:
+					continue
+				case pipeline.StatusRunning:
+					id, err := handler.launchStep(pipelineId, step, byName, results)
+					step.record.CommandId = id
+					if err != nil {
+						status = pipeline.StatusFailed
+						step.record.FailureMessage = err.Error()
+					} else {
+						running[step.Name] = id
+					}
+				}
+
+				statuses[step.Name] = status
+				handler.updateStep(pipelineId, step, status)
+				changed = true
+			}
+		}
+
+		if len(running) == 0 {
+			break
+		}
+
+		select {
+		case result := <-results:
+			delete(running, result.step.Name)
+
+			status := newStepStatus(result.statuses, ctx.Err() != nil)
+			if status == pipeline.StatusFailed {
+				result.step.record.FailureMessage = result.statuses.FailureMessage
+				if result.step.record.FailureMessage == "" {
+					result.step.record.FailureMessage = fmt.Sprintf("command exited with code %d", result.statuses.ExitCode)
+				}
+			}
+
+			statuses[result.step.Name] = status
+			handler.updateStep(pipelineId, result.step, status)
+		case <-cancelled:
+			cancelled = nil
+			for _, id := range running {
+				handler.executeHandler.cancelHandler.cancel(id, fmt.Sprintf("pipeline %d", pipelineId))
+			}
+		case <-time.After(pipelinePollInterval):
+			if cancelled != nil && handler.isCancelRequested(pipelineId) {
+				handler.cancel(pipelineId)
+			}
+		}
+	}
+
+	status := pipeline.Status(statuses)
+	if err := handler.conn.FinishPipeline(pipelineId, status); err != nil {
+		log.Println(err)
+	}
+	log.Printf("pipeline with id = %d is %s\n", pipelineId, status)
+}
+
+// Checks whether cancellation of the pipeline is requested through
+// another server instance.
+func (handler *PipelineHandler) isCancelRequested(pipelineId uint64) bool {
+	requested, err := handler.conn.IsPipelineCancelRequested(pipelineId)
+	if err != nil {
+		log.Println(err)
+	}
+
+	return requested
+}
+
+// Fails pipelines that are left running by the previous process of this
+// server instance: their running steps are failed and pending ones are
+// cancelled. Must be called after commands are recovered and before any
+// pipeline is created.
+func (handler *PipelineHandler) Recover() error {
+	ids, err := handler.conn.GetInstancePipelines(handler.executeHandler.instance, pipeline.StatusRunning)
+	if err != nil {
+		return err
+	}
+
+	for _, pipelineId := range ids {
+		record, err := handler.conn.GetPipeline(pipelineId)
+		if err != nil {
+			return err
+		}
+
+		for _, step := range record.Steps {
+			switch step.Status {
+			case pipeline.StatusPending:
+				step.Status = pipeline.StatusCancelled
+			case pipeline.StatusRunning:
+				step.Status = pipeline.StatusFailed
+				step.FailureMessage = "server is restarted while step was running"
+			default:
+				continue
+			}
+
+			if err := handler.conn.UpdatePipelineStep(pipelineId, step); err != nil {
+				return err
+			}
+		}
+
+		if err := handler.conn.FinishPipeline(pipelineId, pipeline.StatusFailed); err != nil {
+			return err
+		}
+		log.Printf("pipeline with id = %d is failed by the server's restart\n", pipelineId)
+	}
+
+	return nil
+}
+
+// Launches command of the step linked to the pipeline. Outputs of other
+// steps are passed by IDs of their commands. Result of the command is sent
+// into results channel after its finish.
+func (handler *PipelineHandler) launchStep(
+	pipelineId uint64,
+	step *pipelineStep,
+	byName map[string]*pipelineStep,
+	results chan<- stepResult,
+) (uint64, error) {
+	requestBody := step.requestBody.clone()
+
+	commandId := func(name string) (uint64, error) {
+		if id := byName[name].record.CommandId; id != 0 {
+			return id, nil
+		}
+		return 0, fmt.Errorf("step \"%s\" doesn't have a command", name)
+	}
+
+	if step.stdinFromStep != "" {
+		id, err := commandId(step.stdinFromStep)
+		if err != nil {
+			return 0, err
+		}
+		requestBody.StdinFrom = id
+	}
+	for key, name := range step.envFromSteps {
+		id, err := commandId(name)
+		if err != nil {
+			return 0, err
+		}
+
+		if requestBody.EnvFrom == nil {
+			requestBody.EnvFrom = make(map[string]uint64)
+		}
+		requestBody.EnvFrom[key] = id
+	}
+
+	id, finished, err := handler.executeHandler.launch(db.CommandTableRecord{PipelineId: pipelineId}, requestBody)
+	if err != nil {
+		return id, err
+	}
+
+	go func() {
+		<-finished
+
+		statuses, err := handler.conn.GetStatusesById(id)
+		if err != nil {
+			log.Println(err)
+			statuses.Status = db.StatusFailed
+		}
+
+		results <- stepResult{step: step, statuses: statuses}
+	}()
+
+	return id, nil
+}
+
+func (handler *PipelineHandler) updateStep(pipelineId uint64, step *pipelineStep, status string) {
+	step.record.Status = status
+	if err := handler.conn.UpdatePipelineStep(pipelineId, step.record); err != nil {
+		log.Println(err)
+	}
+}
+
+// Returns status of the step by the verdict of its finished command.
+// Interrupted commands of cancelled pipelines are cancelled, not failed.
+func newStepStatus(statuses db.StatusesTableRecord, cancelled bool) string {
+	switch {
+	case isSucceeded(statuses):
+		return pipeline.StatusSucceeded
+	case statuses.Status == db.StatusInterrupted && cancelled:
+		return pipeline.StatusCancelled
+	default:
+		return pipeline.StatusFailed
+	}
+}
+
+// Request body of the pipeline creation.
+type PipelineRequestBody struct {
+	Steps []PipelineStepBody `json:"steps"`
+}
+
+// Step of the pipeline: launch request of the command with its
+// dependencies and condition.
+type PipelineStepBody struct {
+	Name      string   `json:"name"`
+	DependsOn []string `json:"depends_on"`
+	Condition string   `json:"condition"`
+
+	// Steps whose outputs are passed as input and as environment variables
+	// of the step. They must be dependencies of the step.
+	StdinFromStep string            `json:"stdin_from_step,omitempty"`
+	EnvFromSteps  map[string]string `json:"env_from_steps,omitempty"`
+
+	RequestBody
+}
+
+// Checks that outputs are passed only from dependencies of the step.
+func (stepBody *PipelineStepBody) validate() error {
+	if err := stepBody.RequestBody.validate(); err != nil {
+		return err
+	}
+	if stepBody.isDelayed() {
+		return fmt.Errorf("steps of the pipeline can't be delayed")
+	}
+
+	if stepBody.StdinFromStep != "" {
+		if stepBody.StdinFrom != 0 || stepBody.Input != "" {
+			return fmt.Errorf("\"stdin_from_step\", \"stdin_from\" and \"input\" parameters can't be used together")
+		}
+		if !slices.Contains(stepBody.DependsOn, stepBody.StdinFromStep) {
+			return fmt.Errorf("\"stdin_from_step\" must be one of the dependencies")
+		}
+	}
+
+	for key, name := range stepBody.EnvFromSteps {
+		if key == "" || strings.Contains(key, "=") {
+			return fmt.Errorf("invalid \"env_from_steps\" variable name \"%s\"", key)
+		}
+		if _, exists := stepBody.EnvFrom[key]; exists {
+			return fmt.Errorf("variable \"%s\" is passed by both \"env_from_steps\" and \"env_from\"", key)
+		}
+		if !slices.Contains(stepBody.DependsOn, name) {
+			return fmt.Errorf("\"env_from_steps\" variable \"%s\" must be passed from one of the dependencies", key)
+		}
+	}
+
+	return nil
+}
+
+// Validates steps of the pipeline and returns them with their database
+// records.
+func (pipelineBody *PipelineRequestBody) steps() ([]*pipelineStep, []db.PipelineStepTableRecord, error) {
+	steps := make([]*pipelineStep, 0, len(pipelineBody.Steps))
+	graph := make([]pipeline.Step, 0, len(pipelineBody.Steps))
+	records := make([]db.PipelineStepTableRecord, 0, len(pipelineBody.Steps))
+
+	for _, stepBody := range pipelineBody.Steps {
+		if stepBody.Condition == "" {
+			stepBody.Condition = pipeline.ConditionOnSuccess
+		}
+
+		if err := stepBody.validate(); err != nil {
+			return nil, nil, fmt.Errorf("step \"%s\": %w", stepBody.Name, err)
+		}
+
+		// dependencies and condition are stored separately
+		spec, err := json.Marshal(struct {
+			StdinFromStep string            `json:"stdin_from_step,omitempty"`
+			EnvFromSteps  map[string]string `json:"env_from_steps,omitempty"`
+
+			*RequestBody
+		}{
+			StdinFromStep: stepBody.StdinFromStep,
+			EnvFromSteps:  stepBody.EnvFromSteps,
+			RequestBody:   &stepBody.RequestBody,
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+
+		step := &pipelineStep{
+			Step: pipeline.Step{
+				Name:      stepBody.Name,
+				DependsOn: stepBody.DependsOn,
+				Condition: stepBody.Condition,
+			},
+			requestBody:   stepBody.RequestBody.clone(),
+			stdinFromStep: stepBody.StdinFromStep,
+			envFromSteps:  stepBody.EnvFromSteps,
+		}
+		step.record = db.PipelineStepTableRecord{
+			Name:      step.Name,
+			DependsOn: step.DependsOn,
+			Condition: step.Condition,
+			Spec:      spec,
+			Status:    pipeline.StatusPending,
+		}
+
+		steps = append(steps, step)
+		graph = append(graph, step.Step)
+		records = append(records, step.record)
+	}
+
+	if err := pipeline.Validate(graph); err != nil {
+		return nil, nil, err
+	}
+
+	return steps, records, nil
+}
+
+// Step of the pipeline that is being executed.
+type pipelineStep struct {
+	pipeline.Step
+
+	requestBody *RequestBody
+	record      db.PipelineStepTableRecord
+
+	// names of the steps whose outputs are passed into the step
+	stdinFromStep string
+	envFromSteps  map[string]string
+}
+
+// Statuses of the finished command of the step.
+type stepResult struct {
+	step     *pipelineStep
+	statuses db.StatusesTableRecord
+}
+
+// Response body of the pipeline creation.
+type PipelineResponse struct {
+	Id     uint64     `json:"id"`
+	Status string     `json:"status"`
+	Links  GroupLinks `json:"links"`
+}
+
+func newPipelineLinks(pipelineId uint64) GroupLinks {
+	return GroupLinks{
+		Status: fmt.Sprintf("/api/pipelines/%d", pipelineId),
+		Cancel: fmt.Sprintf("/api/pipelines/%d/cancel", pipelineId),
+	}
+}
@@ -0,0 +1,150 @@
+package api
+
+import (
+	"bytes"
+	"database/sql"
+	"db"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type RerunHandler struct {
+	executeHandler *ExecuteHandler
+
+	conn *db.Connection
+}
+
+// Launches new command from the stored one. Optional body contains fields
+// of the launch request that override stored ones.
+func (handler *RerunHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	originId, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+	if err != nil {
+		writeBadRequestError(err, w, r)
+		return
+	}
+
+	if _, err := parseWaitTimeout(r.URL.Query().Get("timeout")); err != nil {
+		writeBadRequestError(err, w, r)
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeBadRequestError(err, w, r)
+		return
+	}
+
+	record, err := handler.conn.GetFullRecordById(originId)
+	if err == sql.ErrNoRows {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		writeInternalServerError(err, w, r)
+		return
+	}
+
+	if err := checkRerunInputs(record); err != nil {
+		writeConflictError(err, w, r)
+		return
+	}
+
+	requestBody, err := newRerunRequestBody(record)
+	if err != nil {
+		writeInternalServerError(err, w, r)
+		return
+	}
+	if len(bytes.TrimSpace(body)) != 0 {
+		if err := json.Unmarshal(body, requestBody); err != nil {
+			writeBadRequestError(err, w, r)
+			return
+		}
+	}
+
+	if err := handler.executeHandler.prepare(requestBody); err != nil {
+		writeBadRequestError(err, w, r)
+		return
+	}
+
+	command := db.CommandTableRecord{
+		Command: requestBody.Command,
+		Group:   requestBody.Group,
+		RunAt:   requestBody.launchTime(time.Now()),
+		RerunOf: originId,
+	}
+
+	// writing database record
+	id, err := handler.conn.InsertRecord(command, newInputRecord(requestBody))
+	if err != nil {
+		handler.executeHandler.removeWorkspace(requestBody)
+		log.Println(err)
+		writeInternalServerError(err, w, r)
+		return
+	}
+	log.Printf("command with id = %d is re-run of command with id = %d\n", id, originId)
+
+	if command.RunAt != nil {
+		handler.executeHandler.writeLaunchResponse(id, db.StatusesTableRecord{Status: db.StatusScheduled}, w, r)
+		return
+	}
+
+	if _, err := handler.executeHandler.start(id, requestBody); err != nil {
+		handler.executeHandler.writeLaunchResponse(id, newStartFailureStatuses(err), w, r)
+		return
+	}
+
+	handler.executeHandler.writeLaunchResponse(id, db.StatusesTableRecord{Status: db.StatusRunning}, w, r)
+}
+
+func NewRerunHandler(conn *db.Connection, executeHandler *ExecuteHandler) (*RerunHandler, error) {
+	if err := checkConnection(conn); err != nil {
+		return nil, err
+	}
+	if err := checkExecuteHandler(executeHandler); err != nil {
+		return nil, err
+	}
+
+	h := new(RerunHandler)
+	h.executeHandler = executeHandler
+	h.conn = conn
+	return h, nil
+}
+
+// Checks that inputs of the command are stored, so re-run gets the same
+// ones.
+func checkRerunInputs(record db.FullCommandRecord) error {
+	// streamed input is stored only if it's small text
+	if record.Input.InputSize != int64(len(record.Input.Input)) {
+		return fmt.Errorf("input of command with id = %d isn't stored", record.Command.Id)
+	}
+
+	// only descriptions of the attached files are stored
+	if len(record.Input.Files) != 0 {
+		return fmt.Errorf("files attached to command with id = %d aren't stored", record.Command.Id)
+	}
+
+	return nil
+}
+
+// Returns request of the re-run: stored request of the command with fresh
+// workspace. Outputs of other commands are not resolved again - their
+// stored values are used.
+func newRerunRequestBody(record db.FullCommandRecord) (*RequestBody, error) {
+	requestBody, err := newStoredRequestBody(record)
+	if err != nil {
+		return nil, err
+	}
+
+	// working directory of the stored command is its old workspace
+	if requestBody.Workspace != nil {
+		requestBody.Workdir = ""
+		requestBody.workspace = ""
+	}
+
+	return requestBody, nil
+}
@@ -0,0 +1,91 @@
+package api
+
+import (
+	"db"
+	"testing"
+)
+
+func TestNewRerunRequestBodyWorkspace(t *testing.T) {
+	record := db.FullCommandRecord{}
+	record.Command.Command = "make report"
+	record.Input.Workdir = "/var/lib/workspaces/42"
+	record.Input.Workspace = &db.WorkspaceTableRecord{
+		Path:    "/var/lib/workspaces/42",
+		Cleanup: CleanupAlways,
+	}
+
+	requestBody, err := newRerunRequestBody(record)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := requestBody.validate(); err != nil {
+		t.Fatalf("re-run of command with workspace must be valid, got %s", err)
+	}
+	if requestBody.Workdir != "" || requestBody.workspace != "" {
+		t.Fatalf("re-run must get fresh workspace, got workdir \"%s\"", requestBody.Workdir)
+	}
+	if requestBody.Workspace == nil || requestBody.Workspace.Cleanup != CleanupAlways {
+		t.Fatalf("re-run must keep workspace options")
+	}
+}
+
+func TestNewRerunRequestBodyWorkdir(t *testing.T) {
+	record := db.FullCommandRecord{}
+	record.Command.Command = "pwd"
+	record.Input.Workdir = "/srv/jobs"
+
+	requestBody, err := newRerunRequestBody(record)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := requestBody.validate(); err != nil {
+		t.Fatal(err)
+	}
+	if requestBody.Workdir != "/srv/jobs" {
+		t.Fatalf("re-run must keep working directory, got \"%s\"", requestBody.Workdir)
+	}
+}
+
+func TestNewRerunRequestBodyStdinFrom(t *testing.T) {
+	record := db.FullCommandRecord{}
+	record.Command.Command = "wc -l"
+	record.Input.StdinFrom = 42
+	record.Input.Input = "resolved output\n"
+
+	requestBody, err := newRerunRequestBody(record)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := requestBody.validate(); err != nil {
+		t.Fatal(err)
+	}
+	if requestBody.StdinFrom != 42 || requestBody.Input != "" {
+		t.Fatalf("re-run must read output of command with id = 42 again")
+	}
+}
+
+func TestCheckRerunInputs(t *testing.T) {
+	record := db.FullCommandRecord{}
+	record.Command.Id = 42
+	record.Input.Input = "amogus\n"
+	record.Input.InputSize = 7
+
+	if err := checkRerunInputs(record); err != nil {
+		t.Fatalf("command with stored input must be re-run, got %s", err)
+	}
+
+	streamed := record
+	streamed.Input.Input = ""
+	if err := checkRerunInputs(streamed); err == nil {
+		t.Fatalf("command with streamed input must not be re-run")
+	}
+
+	uploaded := record
+	uploaded.Input.Files = []db.InputFileTableRecord{{Path: "data.csv", Size: 1024}}
+	if err := checkRerunInputs(uploaded); err == nil {
+		t.Fatalf("command with attached files must not be re-run")
+	}
+}
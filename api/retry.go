@@ -0,0 +1,159 @@
+package api
+
+import (
+	"context"
+	"db"
+	"executor"
+	"log"
+	"math/rand"
+	"retry"
+	"time"
+	"verdict"
+)
+
+// Launches stored command. Commands with retry policy are launched as
+// series of attempts, channel is closed after the last one.
+func (handler *ExecuteHandler) start(id uint64, requestBody *RequestBody) (<-chan struct{}, error) {
+	if requestBody.Retry == nil {
+		stdin, err := handler.openStdin(requestBody)
+		if err != nil {
+			handler.fail(id, requestBody, err)
+			return nil, err
+		}
+
+		return handler.run(id, requestBody, stdin)
+	}
+
+	return handler.runWithRetries(id, requestBody), nil
+}
+
+// Launches attempts of the command until one of them isn't retried by the
+// policy. Every attempt is stored as separate command linked to the parent
+// one, parent gets statuses and outputs of the last attempt. Cancellation
+// of the parent cancels current attempt and stops retries.
+func (handler *ExecuteHandler) runWithRetries(id uint64, requestBody *RequestBody) <-chan struct{} {
+	ctx, cancel := context.WithCancel(context.Background())
+	finished := make(chan struct{})
+
+	if err := handler.conn.SetRunning(id, handler.instance); err != nil {
+		log.Println(err)
+	}
+	// processes belong to the attempts
+	insertEvent(handler.conn, id, db.EventStarted, nil)
+	handler.cancelHandler.insert(id, &runningCommand{cancel: cancel, finished: finished})
+
+	attemptBody := requestBody.clone()
+	attemptBody.keepWorkspace = true
+
+	go func() {
+		defer close(finished)
+
+		var attemptId uint64
+		var statuses db.StatusesTableRecord
+
+		for attempt := 1; ; attempt++ {
+			statuses, attemptId = handler.runAttempt(ctx, id, attempt, attemptBody)
+
+			if ctx.Err() != nil || !shouldRetry(requestBody.Retry, attempt, statuses) {
+				break
+			}
+
+			delay := requestBody.Retry.Delay(attempt, rand.Float64())
+			log.Printf("command with id = %d is retried after %s\n", id, delay)
+
+			select {
+			case <-time.After(delay):
+			case <-ctx.Done():
+			}
+
+			if ctx.Err() != nil {
+				statuses = db.StatusesTableRecord{
+					Status:         db.StatusInterrupted,
+					ExitCode:       -1,
+					Verdict:        verdict.Failed,
+					VerdictMessage: "command is interrupted",
+				}
+				break
+			}
+		}
+
+		outputs := new(db.OutputsTableRecord)
+		if attemptId != 0 {
+			attemptOutputs, err := handler.conn.GetOutputsById(attemptId)
+			if err != nil {
+				log.Println(err)
+			}
+			outputs = &attemptOutputs
+		}
+
+		if err := handler.cleanupWorkspace(id, requestBody, isSucceeded(statuses)); err != nil {
+			log.Printf("command with id = %d can't clean up its workspace: %s\n", id, err)
+		}
+		if err := handler.conn.UpdateRecord(id, outputs, statuses); err != nil {
+			log.Println(err)
+		}
+
+		// removing cancel function of this command
+		handler.cancelHandler.callAndDelete(id)
+	}()
+
+	return finished
+}
+
+// Reports whether attempt with such statuses must be retried by the
+// policy. Attempt is failed by its verdict, not by its exit code.
+func shouldRetry(policy *retry.Policy, attempt int, statuses db.StatusesTableRecord) bool {
+	timedOut := statuses.FailureClass == executor.ErrorClassTimeout
+	return policy.ShouldRetry(attempt, statuses.ExitCode, isSucceeded(statuses), timedOut)
+}
+
+// Stores and launches one attempt of the command and waits for its
+// finish. Returns statuses of the attempt and its ID, that is zero if
+// attempt isn't stored.
+func (handler *ExecuteHandler) runAttempt(
+	ctx context.Context,
+	id uint64,
+	attempt int,
+	requestBody *RequestBody,
+) (db.StatusesTableRecord, uint64) {
+	input := newInputRecord(requestBody)
+	input.Retry = nil
+	// workspace belongs to the parent command
+	input.Workspace = nil
+
+	attemptId, err := handler.conn.InsertRecord(
+		db.CommandTableRecord{Command: requestBody.Command, ParentId: id, Attempt: attempt},
+		input,
+	)
+	if err != nil {
+		log.Printf("attempt %d of command with id = %d isn't stored: %s\n", attempt, id, err)
+		return newStartFailureStatuses(err), 0
+	}
+	log.Printf("launched attempt %d of command with id = %d\n", attempt, id)
+
+	stdin, err := handler.openStdin(requestBody)
+	if err != nil {
+		handler.fail(attemptId, requestBody, err)
+		return newStartFailureStatuses(err), attemptId
+	}
+
+	attemptFinished, err := handler.run(attemptId, requestBody, stdin)
+	if err != nil {
+		return newStartFailureStatuses(err), attemptId
+	}
+
+	select {
+	case <-attemptFinished:
+	case <-ctx.Done():
+		handler.cancelHandler.callAndDelete(attemptId)
+		<-attemptFinished
+	}
+
+	statuses, err := handler.conn.GetStatusesById(attemptId)
+	if err != nil {
+		log.Println(err)
+		statuses = db.StatusesTableRecord{Status: db.StatusFailed, ExitCode: -1}
+	}
+
+	return statuses, attemptId
+}
@@ -0,0 +1,39 @@
+package api
+
+import (
+	"db"
+	"retry"
+	"testing"
+	"verdict"
+)
+
+func TestShouldRetryByVerdict(t *testing.T) {
+	policy := &retry.Policy{MaxAttempts: 3}
+	if err := policy.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	// exit code 1 is accepted by "success.exit_codes"
+	accepted := db.StatusesTableRecord{
+		Status:   db.StatusFinished,
+		ExitCode: 1,
+		Verdict:  verdict.Succeeded,
+	}
+	if shouldRetry(policy, 1, accepted) {
+		t.Fatalf("succeeded attempt with exit code 1 must not be retried")
+	}
+
+	// output assertion doesn't hold
+	asserted := db.StatusesTableRecord{
+		Status:         db.StatusFinished,
+		ExitCode:       0,
+		Verdict:        verdict.Failed,
+		VerdictMessage: "stdout doesn't match \"^OK\"",
+	}
+	if !shouldRetry(policy, 1, asserted) {
+		t.Fatalf("failed attempt with exit code 0 must be retried")
+	}
+	if shouldRetry(policy, 3, asserted) {
+		t.Fatalf("last attempt must not be retried")
+	}
+}
@@ -0,0 +1,371 @@
+package api
+
+import (
+	"cron"
+	"database/sql"
+	"db"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Policies of launching scheduled command when its previous run isn't
+// finished yet
+const (
+	// new run isn't launched. Default policy
+	OverlapSkip = "skip"
+	// new run is launched right after previous runs are finished
+	OverlapQueue = "queue"
+	// previous runs are cancelled and new run is launched
+	OverlapCancelPrevious = "cancel_previous"
+)
+
+type ScheduleHandler struct {
+	executeHandler *ExecuteHandler
+
+	conn *db.Connection
+}
+
+type GetSchedulesHandler struct {
+	conn *db.Connection
+}
+
+type GetScheduleHandler struct {
+	conn *db.Connection
+}
+
+type DeleteScheduleHandler struct {
+	conn *db.Connection
+}
+
+// Creates schedule. Its commands are launched by the Scheduler.
+func (handler *ScheduleHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	scheduleBody := new(ScheduleRequestBody)
+	if err := json.NewDecoder(r.Body).Decode(scheduleBody); err != nil {
+		writeBadRequestError(err, w, r)
+		return
+	}
+
+	schedule, err := scheduleBody.parse()
+	if err != nil {
+		writeBadRequestError(err, w, r)
+		return
+	}
+
+	// command is launched the same way as by "/api/launch"
+	if err := handler.executeHandler.check(&scheduleBody.RequestBody); err != nil {
+		writeBadRequestError(err, w, r)
+		return
+	}
+
+	nextRunAt := schedule.Next(time.Now())
+	if nextRunAt.IsZero() {
+		writeBadRequestError(fmt.Errorf("cron expression \"%s\" is never fired", scheduleBody.Cron), w, r)
+		return
+	}
+
+	spec, err := json.Marshal(&scheduleBody.RequestBody)
+	if err != nil {
+		writeInternalServerError(err, w, r)
+		return
+	}
+
+	record := db.ScheduleTableRecord{
+		Cron:      scheduleBody.Cron,
+		Timezone:  scheduleBody.Timezone,
+		Overlap:   scheduleBody.Overlap,
+		Spec:      spec,
+		Enabled:   true,
+		NextRunAt: &nextRunAt,
+		CreatedAt: time.Now(),
+	}
+
+	record.Id, err = handler.conn.InsertSchedule(record)
+	if err != nil {
+		log.Println(err)
+		writeInternalServerError(err, w, r)
+		return
+	}
+	log.Printf("created schedule with id = %d\n", record.Id)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", fmt.Sprintf("/api/schedules/%d", record.Id))
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(&record)
+}
+
+func (handler *GetSchedulesHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	schedules, err := handler.conn.GetSchedules()
+	if err != nil {
+		writeInternalServerError(err, w, r)
+		return
+	}
+
+	json.NewEncoder(w).Encode(schedules)
+}
+
+// Returns schedule with all of its runs.
+func (handler *GetScheduleHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	scheduleId, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+	if err != nil {
+		writeBadRequestError(err, w, r)
+		return
+	}
+
+	schedule, err := handler.conn.GetSchedule(scheduleId)
+	if err == sql.ErrNoRows {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		writeInternalServerError(err, w, r)
+		return
+	}
+
+	runs, err := handler.conn.GetScheduleCommands(scheduleId)
+	if err != nil {
+		writeInternalServerError(err, w, r)
+		return
+	}
+
+	json.NewEncoder(w).Encode(ScheduleStatus{Schedule: schedule, Runs: runs})
+}
+
+// Disables schedule. History of its runs is kept.
+func (handler *DeleteScheduleHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	scheduleId, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+	if err != nil {
+		writeBadRequestError(err, w, r)
+		return
+	}
+
+	disabled, err := handler.conn.DisableSchedule(scheduleId)
+	if err != nil {
+		writeInternalServerError(err, w, r)
+		return
+	}
+	if !disabled {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func NewScheduleHandler(conn *db.Connection, executeHandler *ExecuteHandler) (*ScheduleHandler, error) {
+	if err := checkConnection(conn); err != nil {
+		return nil, err
+	}
+	if err := checkExecuteHandler(executeHandler); err != nil {
+		return nil, err
+	}
+
+	h := new(ScheduleHandler)
+	h.executeHandler = executeHandler
+	h.conn = conn
+	return h, nil
+}
+
+func NewGetSchedulesHandler(conn *db.Connection) (*GetSchedulesHandler, error) {
+	if err := checkConnection(conn); err != nil {
+		return nil, err
+	}
+
+	h := new(GetSchedulesHandler)
+	h.conn = conn
+	return h, nil
+}
+
+func NewGetScheduleHandler(conn *db.Connection) (*GetScheduleHandler, error) {
+	if err := checkConnection(conn); err != nil {
+		return nil, err
+	}
+
+	h := new(GetScheduleHandler)
+	h.conn = conn
+	return h, nil
+}
+
+func NewDeleteScheduleHandler(conn *db.Connection) (*DeleteScheduleHandler, error) {
+	if err := checkConnection(conn); err != nil {
+		return nil, err
+	}
+
+	h := new(DeleteScheduleHandler)
+	h.conn = conn
+	return h, nil
+}
+
+// Launches commands of the schedules at their fire times and delayed
+// commands at their launch times.
+type Scheduler struct {
+	executeHandler *ExecuteHandler
+
+	conn *db.Connection
+}
+
+// Checks for schedules that must be fired and delayed commands that must
+// be launched with provided interval. Never returns.
+func (scheduler *Scheduler) Run(interval time.Duration) {
+	for {
+		now := time.Now()
+
+		schedules, err := scheduler.conn.GetDueSchedules(now)
+		if err != nil {
+			log.Println(err)
+		}
+
+		for _, schedule := range schedules {
+			if err := scheduler.fire(schedule, now); err != nil {
+				log.Printf("schedule with id = %d isn't fired: %s\n", schedule.Id, err)
+			}
+		}
+
+		ids, err := scheduler.conn.GetDueCommands(now)
+		if err != nil {
+			log.Println(err)
+		}
+
+		for _, id := range ids {
+			if err := scheduler.executeHandler.launchDelayed(id); err != nil {
+				log.Printf("delayed command with id = %d isn't launched: %s\n", id, err)
+			}
+		}
+
+		time.Sleep(interval)
+	}
+}
+
+func NewScheduler(conn *db.Connection, executeHandler *ExecuteHandler) (*Scheduler, error) {
+	if err := checkConnection(conn); err != nil {
+		return nil, err
+	}
+	if err := checkExecuteHandler(executeHandler); err != nil {
+		return nil, err
+	}
+
+	scheduler := new(Scheduler)
+	scheduler.executeHandler = executeHandler
+	scheduler.conn = conn
+	return scheduler, nil
+}
+
+// Launches command of the due schedule according to its overlap policy.
+// Fire times missed while server was down are not caught up: next fire
+// time is always calculated from now.
+func (scheduler *Scheduler) fire(schedule db.ScheduleTableRecord, now time.Time) error {
+	location, err := time.LoadLocation(schedule.Timezone)
+	if err != nil {
+		return err
+	}
+	parsed, err := cron.Parse(schedule.Cron, location)
+	if err != nil {
+		return err
+	}
+
+	runs, err := scheduler.conn.GetScheduleCommands(schedule.Id)
+	if err != nil {
+		return err
+	}
+
+	// runs left unfinished by the restart are interrupted at startup of
+	// their server instance, so status of the run is enough
+	var active []uint64
+	for _, run := range runs {
+		if run.Statuses.Status == db.StatusRunning || run.Statuses.Status == db.StatusPaused {
+			active = append(active, run.Command.Id)
+		}
+	}
+
+	skip := false
+	if len(active) != 0 {
+		switch schedule.Overlap {
+		case OverlapQueue:
+			// fire time is kept until previous runs are finished
+			return nil
+		case OverlapCancelPrevious:
+			for _, id := range active {
+				scheduler.executeHandler.cancelHandler.cancel(id, fmt.Sprintf("schedule %d", schedule.Id))
+			}
+		default:
+			skip = true
+		}
+	}
+
+	// only one server instance launches the run
+	claimed, err := scheduler.conn.ClaimScheduleRun(schedule.Id, *schedule.NextRunAt, parsed.Next(now))
+	if err != nil || !claimed {
+		return err
+	}
+
+	if skip {
+		log.Printf("run of schedule with id = %d is skipped: previous run isn't finished\n", schedule.Id)
+		return nil
+	}
+
+	requestBody := new(RequestBody)
+	if err := json.Unmarshal(schedule.Spec, requestBody); err != nil {
+		return err
+	}
+
+	id, _, err := scheduler.executeHandler.launch(db.CommandTableRecord{ScheduleId: schedule.Id}, requestBody)
+	if id == 0 && err != nil {
+		return err
+	}
+	log.Printf("schedule with id = %d launched command with id = %d\n", schedule.Id, id)
+
+	return nil
+}
+
+// Request body of the schedule creation: cron expression, its timezone,
+// overlap policy and launch request of the command.
+type ScheduleRequestBody struct {
+	Cron     string `json:"cron"`
+	Timezone string `json:"timezone"`
+	Overlap  string `json:"overlap"`
+
+	RequestBody
+}
+
+// Validates request with default values filled and returns parsed cron
+// expression.
+func (scheduleBody *ScheduleRequestBody) parse() (*cron.Schedule, error) {
+	if scheduleBody.Timezone == "" {
+		scheduleBody.Timezone = "UTC"
+	}
+	if scheduleBody.Overlap == "" {
+		scheduleBody.Overlap = OverlapSkip
+	}
+
+	switch scheduleBody.Overlap {
+	case OverlapSkip, OverlapQueue, OverlapCancelPrevious:
+	default:
+		return nil, fmt.Errorf("unknown overlap policy \"%s\"", scheduleBody.Overlap)
+	}
+
+	if err := scheduleBody.RequestBody.validate(); err != nil {
+		return nil, err
+	}
+	if scheduleBody.isDelayed() {
+		return nil, fmt.Errorf("scheduled commands can't be delayed")
+	}
+	if scheduleBody.Stdin == StdinOpen {
+		return nil, fmt.Errorf("scheduled commands can't have open stdin")
+	}
+
+	location, err := time.LoadLocation(scheduleBody.Timezone)
+	if err != nil {
+		return nil, fmt.Errorf("unknown timezone \"%s\"", scheduleBody.Timezone)
+	}
+
+	return cron.Parse(scheduleBody.Cron, location)
+}
+
+// Schedule with all of its runs.
+type ScheduleStatus struct {
+	Schedule db.ScheduleTableRecord    `json:"schedule"`
+	Runs     []db.CommandSummaryRecord `json:"runs"`
+}
@@ -0,0 +1,108 @@
+package api
+
+import (
+	"db"
+	"encoding/json"
+	"executor"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+type SignalHandler struct {
+	cancelHandler *CancelHandler
+
+	conn *db.Connection
+}
+
+// Sends signal to the running command and stores it in the command's
+// history.
+func (handler *SignalHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+	if err != nil {
+		writeBadRequestError(err, w, r)
+		return
+	}
+
+	var requestBody SignalRequestBody
+	if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
+		writeBadRequestError(err, w, r)
+		return
+	}
+
+	signal, err := executor.ParseSignal(requestBody.Signal)
+	if err != nil {
+		writeBadRequestError(err, w, r)
+		return
+	}
+
+	command, exists := handler.cancelHandler.get(id)
+	if !exists {
+		http.NotFound(w, r)
+		return
+	}
+	if command.process == nil {
+		writeConflictError(fmt.Errorf("command with id = %d is run as attempts, signal its current attempt", id), w, r)
+		return
+	}
+
+	if err := command.process.Signal(signal, requestBody.Group); err != nil {
+		writeConflictError(fmt.Errorf("signal isn't delivered: %w", err), w, r)
+		return
+	}
+
+	response := SignalResponse{
+		Id:     id,
+		Signal: "SIG" + strings.TrimPrefix(strings.ToUpper(requestBody.Signal), "SIG"),
+		Pid:    command.process.Pid(),
+		Group:  requestBody.Group,
+	}
+	log.Printf("command with id = %d received %s\n", id, response.Signal)
+
+	insertEvent(handler.conn, id, db.EventSignal, signalEventData{
+		SignalResponse:   response,
+		requestEventData: requestEventData{RemoteAddr: r.RemoteAddr},
+	})
+
+	json.NewEncoder(w).Encode(response)
+}
+
+func NewSignalHandler(conn *db.Connection, cancelHandler *CancelHandler) (*SignalHandler, error) {
+	if err := checkConnection(conn); err != nil {
+		return nil, err
+	}
+	if err := checkCancelHandler(cancelHandler); err != nil {
+		return nil, err
+	}
+
+	h := new(SignalHandler)
+	h.cancelHandler = cancelHandler
+	h.conn = conn
+	return h, nil
+}
+
+// Request body of the signal delivery.
+type SignalRequestBody struct {
+	// Name of the signal with or without "SIG" prefix.
+	Signal string `json:"signal"`
+
+	// Whether signal is sent to the whole process group of the command
+	// instead of its shell only.
+	Group bool `json:"group"`
+}
+
+// Response body of the signal delivery.
+type SignalResponse struct {
+	Id     uint64 `json:"id"`
+	Signal string `json:"signal"`
+	Pid    int    `json:"pid"`
+	Group  bool   `json:"group"`
+}
+
+// Details of the signal event in the command's history.
+type signalEventData struct {
+	SignalResponse
+	requestEventData
+}
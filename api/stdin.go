@@ -0,0 +1,118 @@
+package api
+
+import (
+	"db"
+	"encoding/json"
+	"errors"
+	"executor"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Mode of the standard input that is kept open after the command's input.
+const StdinOpen = "open"
+
+// Max size of the data written into stdin by one request.
+const MaxStdinChunkSize = 1 << 20
+
+// Time for the command to read written data.
+const stdinWriteTimeout = time.Second * 30
+
+type StdinHandler struct {
+	cancelHandler *CancelHandler
+
+	conn *db.Connection
+}
+
+// Writes request body into the open stdin of the running command. With
+// "close_stdin=true" query parameter stdin is closed afterwards. Every
+// written chunk is stored.
+func (handler *StdinHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+	if err != nil {
+		writeBadRequestError(err, w, r)
+		return
+	}
+
+	closeStdin := false
+	if value := r.URL.Query().Get("close_stdin"); value != "" {
+		closeStdin, err = strconv.ParseBool(value)
+		if err != nil {
+			writeBadRequestError(err, w, r)
+			return
+		}
+	}
+
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, MaxStdinChunkSize))
+	if err != nil {
+		writeBadRequestError(err, w, r)
+		return
+	}
+
+	command, exists := handler.cancelHandler.get(id)
+	if !exists {
+		http.NotFound(w, r)
+		return
+	}
+	if command.process == nil {
+		writeConflictError(executor.ErrStdinClosed, w, r)
+		return
+	}
+
+	response := StdinResponse{Id: id}
+
+	if len(data) != 0 {
+		response.Written, err = command.process.WriteStdin(data, stdinWriteTimeout)
+		if response.Written != 0 {
+			chunk := db.StdinChunkTableRecord{Data: data[:response.Written], RemoteAddr: r.RemoteAddr}
+			if err := handler.conn.InsertStdinChunk(id, chunk); err != nil {
+				log.Println(err)
+			}
+		}
+		if errors.Is(err, executor.ErrStdinClosed) {
+			writeConflictError(err, w, r)
+			return
+		}
+		if err != nil {
+			writeConflictError(fmt.Errorf("%d bytes are written into stdin: %w", response.Written, err), w, r)
+			return
+		}
+	}
+
+	if closeStdin {
+		if err := command.process.CloseStdin(); err != nil {
+			writeConflictError(err, w, r)
+			return
+		}
+		response.Closed = true
+
+		insertEvent(handler.conn, id, db.EventStdinClosed, requestEventData{RemoteAddr: r.RemoteAddr})
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+func NewStdinHandler(conn *db.Connection, cancelHandler *CancelHandler) (*StdinHandler, error) {
+	if err := checkConnection(conn); err != nil {
+		return nil, err
+	}
+	if err := checkCancelHandler(cancelHandler); err != nil {
+		return nil, err
+	}
+
+	h := new(StdinHandler)
+	h.cancelHandler = cancelHandler
+	h.conn = conn
+	return h, nil
+}
+
+// Response body of the stdin writing.
+type StdinResponse struct {
+	Id      uint64 `json:"id"`
+	Written int    `json:"written"`
+	Closed  bool   `json:"closed"`
+}
@@ -0,0 +1,66 @@
+package api
+
+import (
+	"bytes"
+	"db"
+	"fmt"
+	"io"
+	"log"
+	"verdict"
+)
+
+// Populates verdict of the command by its statuses and outputs. Only
+// finished commands are checked against the criteria, default criteria
+// are used if there are none.
+func (handler *ExecuteHandler) judge(
+	id uint64,
+	statuses *db.StatusesTableRecord,
+	outputs *db.OutputsTableRecord,
+	criteria *verdict.Criteria,
+) {
+	if statuses.Status != db.StatusFinished {
+		statuses.Verdict = verdict.Failed
+		statuses.VerdictMessage = fmt.Sprintf("command is %s", statuses.Status)
+		return
+	}
+
+	if criteria == nil {
+		criteria = new(verdict.Criteria)
+	}
+
+	var err error
+	statuses.Verdict, statuses.VerdictMessage, err = criteria.Evaluate(
+		statuses.ExitCode,
+		handler.outputsOpener(outputs),
+	)
+	if err != nil {
+		log.Printf("command with id = %d can't be checked: %s\n", id, err)
+		statuses.VerdictMessage = fmt.Sprintf("outputs can't be checked: %s", err)
+	}
+}
+
+// Returns opener of the command's streams that are kept either in the
+// database or in the storage.
+func (handler *ExecuteHandler) outputsOpener(outputs *db.OutputsTableRecord) verdict.Opener {
+	return func(stream string) (io.ReadCloser, error) {
+		data, key := outputs.Output, outputs.OutputKey
+		if stream == verdict.StreamStderr {
+			data, key = outputs.Errors, outputs.ErrorsKey
+		}
+
+		if key != "" {
+			return handler.store.Open(key)
+		}
+		return io.NopCloser(bytes.NewReader(data)), nil
+	}
+}
+
+// Reports whether command is finished successfully. Commands stored
+// before verdicts are succeeded if their exit code is 0.
+func isSucceeded(statuses db.StatusesTableRecord) bool {
+	if statuses.Verdict != "" {
+		return statuses.Verdict == verdict.Succeeded
+	}
+
+	return statuses.Status == db.StatusFinished && statuses.ExitCode == 0
+}
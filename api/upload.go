@@ -0,0 +1,290 @@
+package api
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"db"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"log"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"slices"
+	"unicode/utf8"
+	"workspace"
+)
+
+// Serves launch request in form of "multipart/form-data". Such request
+// consists of parts:
+//
+//	"request" - JSON RequestBody, must be the first part
+//	"file"    - optional files written into fresh working directory of the
+//	            command before its launch. Part's file name is a relative
+//	            path of the file and "X-File-Mode" header is its octal mode
+//	"stdin"   - optional input of the command, must be the last part
+//
+// Stdin part is piped directly into the command without buffering, so
+// response is sent only after the command reads all of it or finishes.
+func (handler *ExecuteHandler) serveMultipart(w http.ResponseWriter, r *http.Request) {
+	reader, err := r.MultipartReader()
+	if err != nil {
+		writeBadRequestError(err, w, r)
+		return
+	}
+
+	requestBody, rawRequest, err := readRequestPart(reader)
+	if err != nil {
+		writeBadRequestError(err, w, r)
+		return
+	}
+
+	// only "request" part is compared on reuse of idempotency key
+	command := newCommandRecord(requestBody, r, rawRequest)
+	if handler.replayIdempotent(command, w, r) {
+		return
+	}
+
+	if err := handler.prepare(requestBody); err != nil {
+		writeBadRequestError(err, w, r)
+		return
+	}
+
+	// created workspace is removed if command isn't launched
+	launched := false
+	defer func() {
+		if !launched {
+			handler.removeWorkspace(requestBody)
+		}
+	}()
+
+	var stdinPart *multipart.Part
+	var files []db.InputFileTableRecord
+
+	for stdinPart == nil {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			writeBadRequestError(err, w, r)
+			return
+		}
+
+		switch part.FormName() {
+		case "file":
+			file, err := handler.writeFilePart(requestBody, part)
+			if err != nil {
+				writeBadRequestError(err, w, r)
+				return
+			}
+			files = append(files, file)
+		case "stdin":
+			stdinPart = part
+		default:
+			writeBadRequestError(fmt.Errorf("unexpected part \"%s\"", part.FormName()), w, r)
+			return
+		}
+	}
+
+	if stdinPart != nil && (requestBody.Input != "" || requestBody.StdinFrom != 0) {
+		writeBadRequestError(fmt.Errorf("\"input\" and \"stdin_from\" parameters and \"stdin\" part can't be used together"), w, r)
+		return
+	}
+
+	if stdinPart != nil && command.RunAt != nil {
+		writeBadRequestError(fmt.Errorf("\"stdin\" part can't be used with delayed launch"), w, r)
+		return
+	}
+	if stdinPart != nil && requestBody.Retry != nil {
+		writeBadRequestError(fmt.Errorf("\"stdin\" part can't be used with retry policy"), w, r)
+		return
+	}
+
+	input := newInputRecord(requestBody)
+	if stdinPart != nil {
+		// size and hash are unknown until input is read
+		input.InputSize, input.InputSha256 = 0, ""
+	}
+	input.Files = files
+
+	// writing database record
+	id, err := handler.conn.InsertRecord(command, input)
+	if err == db.ErrDuplicateIdempotencyKey && handler.replayIdempotent(command, w, r) {
+		return
+	}
+	if err != nil {
+		log.Println(err)
+		writeInternalServerError(err, w, r)
+		return
+	}
+	launched = true
+
+	if command.RunAt != nil {
+		handler.writeLaunchResponse(id, db.StatusesTableRecord{Status: db.StatusScheduled}, w, r)
+		return
+	}
+
+	if stdinPart == nil {
+		if _, err := handler.start(id, requestBody); err != nil {
+			handler.writeLaunchResponse(id, newStartFailureStatuses(err), w, r)
+			return
+		}
+
+		handler.writeLaunchResponse(id, db.StatusesTableRecord{Status: db.StatusRunning}, w, r)
+		return
+	}
+
+	recorder := newStdinRecorder(stdinPart, handler.outputThreshold)
+	finished, err := handler.run(id, requestBody, recorder)
+	if err != nil {
+		handler.writeLaunchResponse(id, newStartFailureStatuses(err), w, r)
+		return
+	}
+
+	// request body must stay open until the command reads it
+	select {
+	case <-recorder.done:
+	case <-finished:
+	}
+
+	if err := handler.conn.UpdateInput(id, recorder.record()); err != nil {
+		log.Printf("command with id = %d can't store its input: %s\n", id, err)
+	}
+
+	handler.writeLaunchResponse(id, db.StatusesTableRecord{Status: db.StatusRunning}, w, r)
+}
+
+// Writes file part into workspace of the command. Workspace with default
+// options is created if the command doesn't have it yet.
+func (handler *ExecuteHandler) writeFilePart(
+	requestBody *RequestBody,
+	part *multipart.Part,
+) (db.InputFileTableRecord, error) {
+	// part.FileName() strips directories, so raw parameter is used
+	_, params, err := mime.ParseMediaType(part.Header.Get("Content-Disposition"))
+	if err != nil {
+		return db.InputFileTableRecord{}, err
+	}
+	path := params["filename"]
+
+	mode, err := workspace.ParseMode(part.Header.Get("X-File-Mode"))
+	if err != nil {
+		return db.InputFileTableRecord{}, err
+	}
+
+	if requestBody.workspace == "" {
+		if requestBody.Workdir != "" {
+			return db.InputFileTableRecord{}, fmt.Errorf("files can't be attached to the command with \"workdir\"")
+		}
+
+		requestBody.Workspace = new(WorkspaceOptions)
+		if err := handler.createWorkspace(requestBody); err != nil {
+			return db.InputFileTableRecord{}, err
+		}
+	}
+
+	file, err := workspace.WriteFile(requestBody.Workdir, path, mode, part)
+	if err != nil {
+		return db.InputFileTableRecord{}, err
+	}
+
+	return db.InputFileTableRecord{
+		Path:   file.Path,
+		Mode:   uint32(file.Mode),
+		Size:   file.Size,
+		Sha256: file.Sha256,
+	}, nil
+}
+
+func isMultipartRequest(r *http.Request) bool {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return err == nil && mediaType == "multipart/form-data"
+}
+
+// Reads "request" part. Returns parsed request and its raw content.
+func readRequestPart(reader *multipart.Reader) (*RequestBody, []byte, error) {
+	part, err := reader.NextPart()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer part.Close()
+
+	if part.FormName() != "request" {
+		return nil, nil, fmt.Errorf("first part must be \"request\", got \"%s\"", part.FormName())
+	}
+
+	rawRequest, err := io.ReadAll(part)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	requestBody := new(RequestBody)
+	if err := json.Unmarshal(rawRequest, requestBody); err != nil {
+		return nil, nil, err
+	}
+
+	return requestBody, rawRequest, nil
+}
+
+// Reader that passes command's input through and remembers its size,
+// SHA-256 and, if it is small enough, its content.
+type stdinRecorder struct {
+	reader io.Reader
+
+	hash  hash.Hash
+	size  int64
+	limit int
+	head  bytes.Buffer
+
+	// closed when reader returns any error including io.EOF
+	done chan struct{}
+	err  error
+}
+
+func newStdinRecorder(reader io.Reader, limit int) *stdinRecorder {
+	recorder := new(stdinRecorder)
+	recorder.reader = reader
+	recorder.hash = sha256.New()
+	recorder.limit = limit
+	recorder.done = make(chan struct{})
+	return recorder
+}
+
+func (recorder *stdinRecorder) Read(p []byte) (int, error) {
+	n, err := recorder.reader.Read(p)
+
+	recorder.hash.Write(p[:n])
+	recorder.size += int64(n)
+	if recorder.size <= int64(recorder.limit) {
+		recorder.head.Write(p[:n])
+	} else {
+		recorder.head.Reset()
+	}
+
+	if err != nil && recorder.err == nil {
+		recorder.err = err
+		close(recorder.done)
+	}
+
+	return n, err
+}
+
+// Returns inputs record of the consumed input. Content is kept only if it
+// is small text that fits into TEXT column: valid UTF-8 without NUL bytes.
+func (recorder *stdinRecorder) record() db.InputTableRecord {
+	input := db.InputTableRecord{
+		InputSize:   recorder.size,
+		InputSha256: hex.EncodeToString(recorder.hash.Sum(nil)),
+	}
+
+	head := recorder.head.Bytes()
+	if recorder.size <= int64(recorder.limit) && utf8.Valid(head) && !slices.Contains(head, 0) {
+		input.Input = recorder.head.String()
+	}
+
+	return input
+}
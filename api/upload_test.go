@@ -0,0 +1,45 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestStdinRecorderRecord(t *testing.T) {
+	cases := []struct {
+		name  string
+		input string
+		limit int
+		kept  bool
+	}{
+		{name: "text", input: "amogus\n", limit: 16, kept: true},
+		{name: "nul", input: "a\x00b", limit: 16, kept: false},
+		{name: "binary", input: "\xff\xfe", limit: 16, kept: false},
+		{name: "large", input: strings.Repeat("sus", 10), limit: 16, kept: false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			recorder := newStdinRecorder(strings.NewReader(c.input), c.limit)
+			if _, err := io.Copy(io.Discard, recorder); err != nil {
+				t.Fatal(err)
+			}
+
+			input := recorder.record()
+
+			hash := sha256.Sum256([]byte(c.input))
+			if input.InputSha256 != hex.EncodeToString(hash[:]) {
+				t.Fatalf("wrong SHA-256 of the input")
+			}
+			if input.InputSize != int64(len(c.input)) {
+				t.Fatalf("size must be %d, got %d", len(c.input), input.InputSize)
+			}
+			if kept := input.Input == c.input; kept != c.kept {
+				t.Fatalf("input must be kept: %t, got \"%s\"", c.kept, input.Input)
+			}
+		})
+	}
+}
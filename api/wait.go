@@ -0,0 +1,138 @@
+package api
+
+import (
+	"database/sql"
+	"db"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Default and maximal time of waiting for command's finish
+const (
+	DefaultWaitTimeout = time.Second * 30
+	MaxWaitTimeout     = time.Minute * 10
+)
+
+// Interval of checking statuses of commands that are not launched by this
+// server instance
+const waitPollInterval = time.Second
+
+type WaitHandler struct {
+	cancelHandler *CancelHandler
+
+	conn *db.Connection
+}
+
+// Waits for command's finish. Responds with full command record if command
+// is finished in time or with "202 Accepted" and its current status
+// otherwise.
+func (handler *WaitHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+	if err != nil {
+		writeBadRequestError(err, w, r)
+		return
+	}
+
+	timeout, err := parseWaitTimeout(r.URL.Query().Get("timeout"))
+	if err != nil {
+		writeBadRequestError(err, w, r)
+		return
+	}
+
+	record, err := waitForCommand(handler.conn, handler.cancelHandler, id, timeout)
+	if err == sql.ErrNoRows {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		writeInternalServerError(err, w, r)
+		return
+	}
+
+	writeWaitResponse(record, w, r)
+}
+
+func NewWaitHandler(conn *db.Connection, cancelHandler *CancelHandler) (*WaitHandler, error) {
+	if err := checkConnection(conn); err != nil {
+		return nil, err
+	}
+	if err := checkCancelHandler(cancelHandler); err != nil {
+		return nil, err
+	}
+
+	h := new(WaitHandler)
+	h.cancelHandler = cancelHandler
+	h.conn = conn
+	return h, nil
+}
+
+// Waits until command is in terminal status or timeout is passed. Returns
+// the latest record of the command.
+func waitForCommand(
+	conn *db.Connection,
+	cancelHandler *CancelHandler,
+	id uint64,
+	timeout time.Duration,
+) (db.FullCommandRecord, error) {
+	deadline := time.After(timeout)
+
+	for {
+		// running command notifies about its finish by itself
+		var finished <-chan struct{}
+		if command, exists := cancelHandler.get(id); exists {
+			finished = command.finished
+		}
+
+		record, err := conn.GetFullRecordById(id)
+		if err != nil || db.IsTerminalStatus(record.Statuses.Status) {
+			return record, err
+		}
+
+		select {
+		case <-finished:
+		case <-time.After(waitPollInterval):
+		case <-deadline:
+			return record, nil
+		}
+	}
+}
+
+// Parses timeout in seconds. Empty timeout is DefaultWaitTimeout, timeouts
+// greater than MaxWaitTimeout are truncated.
+func parseWaitTimeout(value string) (time.Duration, error) {
+	if value == "" {
+		return DefaultWaitTimeout, nil
+	}
+
+	seconds, err := strconv.ParseFloat(value, 64)
+	if err != nil || math.IsNaN(seconds) || math.IsInf(seconds, 0) || seconds < 0 {
+		return 0, fmt.Errorf("\"timeout\" parameter must be non-negative number of seconds")
+	}
+
+	// truncated before conversion, large values overflow the duration
+	if seconds > MaxWaitTimeout.Seconds() {
+		return MaxWaitTimeout, nil
+	}
+
+	return time.Duration(seconds * float64(time.Second)), nil
+}
+
+// Responds with full command record if command is in terminal status and
+// with "202 Accepted" and its current status otherwise.
+func writeWaitResponse(record db.FullCommandRecord, w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if !db.IsTerminalStatus(record.Statuses.Status) {
+		response := newLaunchResponse(record.Command.Id, record.Statuses)
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(&response)
+		return
+	}
+
+	populateLinks(&record)
+	json.NewEncoder(w).Encode(&record)
+}
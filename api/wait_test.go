@@ -0,0 +1,37 @@
+package api
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseWaitTimeout(t *testing.T) {
+	cases := []struct {
+		value   string
+		timeout time.Duration
+		valid   bool
+	}{
+		{value: "", timeout: DefaultWaitTimeout, valid: true},
+		{value: "0", timeout: 0, valid: true},
+		{value: "1.5", timeout: time.Millisecond * 1500, valid: true},
+		{value: "3600", timeout: MaxWaitTimeout, valid: true},
+		{value: "1e300", timeout: MaxWaitTimeout, valid: true},
+		{value: "-1", valid: false},
+		{value: "NaN", valid: false},
+		{value: "Inf", valid: false},
+		{value: "-Inf", valid: false},
+		{value: "amogus", valid: false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.value, func(t *testing.T) {
+			timeout, err := parseWaitTimeout(c.value)
+			if valid := err == nil; valid != c.valid {
+				t.Fatalf("timeout \"%s\" must be valid: %t, got error \"%v\"", c.value, c.valid, err)
+			}
+			if c.valid && timeout != c.timeout {
+				t.Fatalf("timeout must be %s, got %s", c.timeout, timeout)
+			}
+		})
+	}
+}
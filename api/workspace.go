@@ -0,0 +1,161 @@
+package api
+
+import (
+	"db"
+	"fmt"
+	"log"
+	"time"
+	"workspace"
+)
+
+// Name of the environment variable with workspace path of the command
+const WorkspaceEnv = "WORKSPACE"
+
+// Retention time of the workspace whose cleanup policy isn't provided
+const DefaultRetainHours = 24
+
+// Cleanup policies of the workspaces
+const (
+	// workspace is never removed
+	CleanupNever = "never"
+	// workspace is removed right after command's finish
+	CleanupAlways = "always"
+	// workspace is removed if command is succeeded by its verdict
+	CleanupOnSuccess = "on_success"
+	// workspace is removed after "retain_hours" hours since command's finish
+	CleanupRetain = "retain"
+)
+
+// Options of the temporary working directory created for the command.
+type WorkspaceOptions struct {
+	// Name of the template directory that is copied into the workspace.
+	Template string `json:"template"`
+
+	Cleanup     string `json:"cleanup"`
+	RetainHours int    `json:"retain_hours"`
+}
+
+func (options *WorkspaceOptions) validate() error {
+	switch options.Cleanup {
+	case "", CleanupNever, CleanupAlways, CleanupOnSuccess:
+	case CleanupRetain:
+		if options.RetainHours <= 0 {
+			return fmt.Errorf("\"retain_hours\" parameter must be positive")
+		}
+	default:
+		return fmt.Errorf("unknown cleanup policy \"%s\"", options.Cleanup)
+	}
+
+	return nil
+}
+
+// Removes workspaces retention time of which is passed.
+type WorkspaceJanitor struct {
+	workspaces *workspace.Manager
+
+	conn *db.Connection
+}
+
+// Checks for expired workspaces with provided interval. Never returns.
+func (janitor *WorkspaceJanitor) Run(interval time.Duration) {
+	for {
+		expired, err := janitor.conn.GetExpiredWorkspaces()
+		if err != nil {
+			log.Println(err)
+		}
+
+		for _, record := range expired {
+			if err := janitor.workspaces.Remove(record.Path); err != nil {
+				log.Printf("workspace of command with id = %d can't be removed: %s\n", record.Id(), err)
+				continue
+			}
+			if err := janitor.conn.SetWorkspaceRemoved(record.Id()); err != nil {
+				log.Println(err)
+				continue
+			}
+
+			log.Printf("workspace of command with id = %d is removed\n", record.Id())
+		}
+
+		time.Sleep(interval)
+	}
+}
+
+func NewWorkspaceJanitor(conn *db.Connection, workspaces *workspace.Manager) (*WorkspaceJanitor, error) {
+	if err := checkConnection(conn); err != nil {
+		return nil, err
+	}
+	if err := checkWorkspaces(workspaces); err != nil {
+		return nil, err
+	}
+
+	janitor := new(WorkspaceJanitor)
+	janitor.workspaces = workspaces
+	janitor.conn = conn
+	return janitor, nil
+}
+
+// Fills default cleanup policy: workspace is retained for a limited time,
+// so workspaces aren't left on the disk forever unless asked for.
+func (options *WorkspaceOptions) setDefaults() {
+	if options.Cleanup != "" {
+		return
+	}
+
+	options.Cleanup = CleanupRetain
+	if options.RetainHours <= 0 {
+		options.RetainHours = DefaultRetainHours
+	}
+}
+
+// Creates workspace for the command and makes it command's working
+// directory.
+func (handler *ExecuteHandler) createWorkspace(requestBody *RequestBody) error {
+	requestBody.Workspace.setDefaults()
+
+	dir, err := handler.workspaces.Create(requestBody.Workspace.Template)
+	if err != nil {
+		return err
+	}
+
+	requestBody.Workdir = dir
+	requestBody.workspace = dir
+	return nil
+}
+
+// Removes workspace of the command that wasn't launched.
+func (handler *ExecuteHandler) removeWorkspace(requestBody *RequestBody) {
+	if requestBody.workspace == "" {
+		return
+	}
+
+	if err := handler.workspaces.Remove(requestBody.workspace); err != nil {
+		log.Println(err)
+	}
+}
+
+// Applies cleanup policy to the workspace of finished command.
+func (handler *ExecuteHandler) cleanupWorkspace(id uint64, requestBody *RequestBody, succeeded bool) error {
+	if requestBody.workspace == "" || requestBody.keepWorkspace {
+		return nil
+	}
+
+	switch requestBody.Workspace.Cleanup {
+	case CleanupOnSuccess:
+		if !succeeded {
+			return nil
+		}
+	case CleanupRetain:
+		expiresAt := time.Now().Add(time.Hour * time.Duration(requestBody.Workspace.RetainHours))
+		return handler.conn.SetWorkspaceExpiration(id, expiresAt)
+	case CleanupAlways:
+	default:
+		return nil
+	}
+
+	if err := handler.workspaces.Remove(requestBody.workspace); err != nil {
+		return err
+	}
+
+	return handler.conn.SetWorkspaceRemoved(id)
+}
@@ -0,0 +1,31 @@
+package api
+
+import "testing"
+
+func TestWorkspaceOptionsSetDefaults(t *testing.T) {
+	cases := []struct {
+		name        string
+		options     WorkspaceOptions
+		cleanup     string
+		retainHours int
+	}{
+		{name: "empty", options: WorkspaceOptions{}, cleanup: CleanupRetain, retainHours: DefaultRetainHours},
+		{name: "hours", options: WorkspaceOptions{RetainHours: 2}, cleanup: CleanupRetain, retainHours: 2},
+		{name: "never", options: WorkspaceOptions{Cleanup: CleanupNever}, cleanup: CleanupNever, retainHours: 0},
+		{name: "always", options: WorkspaceOptions{Cleanup: CleanupAlways}, cleanup: CleanupAlways, retainHours: 0},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			options := c.options
+			options.setDefaults()
+
+			if options.Cleanup != c.cleanup {
+				t.Fatalf("cleanup policy must be \"%s\", got \"%s\"", c.cleanup, options.Cleanup)
+			}
+			if options.RetainHours != c.retainHours {
+				t.Fatalf("retention hours must be %d, got %d", c.retainHours, options.RetainHours)
+			}
+		})
+	}
+}
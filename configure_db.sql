@@ -1,27 +1,223 @@
+CREATE TABLE IF NOT EXISTS batches (
+    id SERIAL PRIMARY KEY,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+);
+
+CREATE TABLE IF NOT EXISTS schedules (
+    id SERIAL PRIMARY KEY,
+    cron TEXT NOT NULL,
+    timezone TEXT NOT NULL,
+    overlap TEXT NOT NULL,
+    spec JSONB NOT NULL,
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    next_run_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+);
+
+CREATE TABLE IF NOT EXISTS pipelines (
+    id SERIAL PRIMARY KEY,
+    status TEXT NOT NULL DEFAULT 'running',
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    finished_at TIMESTAMP WITH TIME ZONE,
+    instance TEXT,
+    cancel_requested BOOLEAN NOT NULL DEFAULT FALSE
+);
+
+ALTER TABLE pipelines
+    ADD COLUMN IF NOT EXISTS instance TEXT,
+    ADD COLUMN IF NOT EXISTS cancel_requested BOOLEAN NOT NULL DEFAULT FALSE;
+
 CREATE TABLE IF NOT EXISTS commands (
     id SERIAL PRIMARY KEY,
-    command TEXT NOT NULL
+    command TEXT NOT NULL,
+    idempotency_key TEXT UNIQUE,
+    request_sha256 TEXT,
+    batch_id INTEGER REFERENCES batches (id),
+    group_name TEXT,
+    schedule_id INTEGER REFERENCES schedules (id),
+    run_at TIMESTAMP WITH TIME ZONE,
+    parent_id INTEGER REFERENCES commands (id),
+    attempt INTEGER,
+    rerun_of INTEGER REFERENCES commands (id),
+    pipeline_id INTEGER REFERENCES pipelines (id)
 );
 
-DROP TYPE IF EXISTS env_entry CASCADE;
+-- columns added after the table was created
+ALTER TABLE commands
+    ADD COLUMN IF NOT EXISTS idempotency_key TEXT UNIQUE,
+    ADD COLUMN IF NOT EXISTS request_sha256 TEXT,
+    ADD COLUMN IF NOT EXISTS batch_id INTEGER REFERENCES batches (id),
+    ADD COLUMN IF NOT EXISTS group_name TEXT,
+    ADD COLUMN IF NOT EXISTS schedule_id INTEGER REFERENCES schedules (id),
+    ADD COLUMN IF NOT EXISTS run_at TIMESTAMP WITH TIME ZONE,
+    ADD COLUMN IF NOT EXISTS parent_id INTEGER REFERENCES commands (id),
+    ADD COLUMN IF NOT EXISTS attempt INTEGER,
+    ADD COLUMN IF NOT EXISTS rerun_of INTEGER REFERENCES commands (id),
+    ADD COLUMN IF NOT EXISTS pipeline_id INTEGER REFERENCES pipelines (id);
 
-CREATE TYPE env_entry AS (key TEXT, value TEXT);
+CREATE INDEX IF NOT EXISTS commands_group_name_idx ON commands (group_name);
+
+CREATE INDEX IF NOT EXISTS commands_schedule_id_idx ON commands (schedule_id);
+
+CREATE INDEX IF NOT EXISTS commands_parent_id_idx ON commands (parent_id);
+
+CREATE INDEX IF NOT EXISTS commands_rerun_of_idx ON commands (rerun_of);
+
+CREATE INDEX IF NOT EXISTS commands_pipeline_id_idx ON commands (pipeline_id);
+
+-- type is kept, dropping it would drop "env" column of the stored inputs
+DO $$
+BEGIN
+    CREATE TYPE env_entry AS (key TEXT, value TEXT);
+EXCEPTION
+    WHEN duplicate_object THEN NULL;
+END
+$$;
 
 CREATE TABLE IF NOT EXISTS inputs (
     id SERIAL REFERENCES commands (id),
+    workdir TEXT,
     input TEXT,
-    env env_entry ARRAY
+    env env_entry ARRAY,
+    input_size BIGINT,
+    input_sha256 TEXT,
+    artifacts TEXT ARRAY,
+    stdin_from INTEGER REFERENCES commands (id),
+    env_from JSONB,
+    timeout_seconds DOUBLE PRECISION,
+    retry JSONB,
+    success JSONB
+);
+
+ALTER TABLE inputs
+    ADD COLUMN IF NOT EXISTS env env_entry ARRAY,
+    ADD COLUMN IF NOT EXISTS workdir TEXT,
+    ADD COLUMN IF NOT EXISTS input_size BIGINT,
+    ADD COLUMN IF NOT EXISTS input_sha256 TEXT,
+    ADD COLUMN IF NOT EXISTS artifacts TEXT ARRAY,
+    ADD COLUMN IF NOT EXISTS stdin_from INTEGER REFERENCES commands (id),
+    ADD COLUMN IF NOT EXISTS env_from JSONB,
+    ADD COLUMN IF NOT EXISTS timeout_seconds DOUBLE PRECISION,
+    ADD COLUMN IF NOT EXISTS retry JSONB,
+    ADD COLUMN IF NOT EXISTS success JSONB;
+
+CREATE TABLE IF NOT EXISTS workspaces (
+    id INTEGER REFERENCES commands (id),
+    path TEXT NOT NULL,
+    template TEXT,
+    cleanup TEXT NOT NULL,
+    retain_hours INTEGER,
+    expires_at TIMESTAMP WITH TIME ZONE,
+    removed_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE TABLE IF NOT EXISTS input_files (
+    id INTEGER REFERENCES commands (id),
+    path TEXT NOT NULL,
+    mode INTEGER NOT NULL,
+    size BIGINT NOT NULL,
+    sha256 TEXT NOT NULL
 );
 
 CREATE TABLE IF NOT EXISTS outputs (
     id SERIAL REFERENCES commands (id),
-    output TEXT,
-    errors TEXT
+    output BYTEA,
+    errors BYTEA,
+    output_key TEXT,
+    output_size BIGINT,
+    errors_key TEXT,
+    errors_size BIGINT,
+    output_partial TEXT,
+    errors_partial TEXT
 );
 
+ALTER TABLE outputs
+    ADD COLUMN IF NOT EXISTS output_key TEXT,
+    ADD COLUMN IF NOT EXISTS output_size BIGINT,
+    ADD COLUMN IF NOT EXISTS errors_key TEXT,
+    ADD COLUMN IF NOT EXISTS errors_size BIGINT,
+    ADD COLUMN IF NOT EXISTS output_partial TEXT,
+    ADD COLUMN IF NOT EXISTS errors_partial TEXT;
+
+-- streams were stored as text before binary outputs were supported
+DO $$
+BEGIN
+    IF (
+        SELECT data_type FROM information_schema.columns
+        WHERE table_name = 'outputs' AND column_name = 'output'
+    ) = 'text' THEN
+        ALTER TABLE outputs ALTER COLUMN output TYPE BYTEA USING convert_to(output, 'UTF8');
+    END IF;
+    IF (
+        SELECT data_type FROM information_schema.columns
+        WHERE table_name = 'outputs' AND column_name = 'errors'
+    ) = 'text' THEN
+        ALTER TABLE outputs ALTER COLUMN errors TYPE BYTEA USING convert_to(errors, 'UTF8');
+    END IF;
+END
+$$;
+
 CREATE TABLE IF NOT EXISTS statuses (
     id SERIAL REFERENCES commands (id),
-    exit_code INTEGER
+    status TEXT NOT NULL DEFAULT 'created',
+    exit_code INTEGER,
+    failure_class TEXT,
+    failure_message TEXT,
+    verdict TEXT,
+    verdict_message TEXT,
+    instance TEXT
+);
+
+ALTER TABLE statuses
+    ADD COLUMN IF NOT EXISTS status TEXT NOT NULL DEFAULT 'created',
+    ADD COLUMN IF NOT EXISTS failure_class TEXT,
+    ADD COLUMN IF NOT EXISTS failure_message TEXT,
+    ADD COLUMN IF NOT EXISTS verdict TEXT,
+    ADD COLUMN IF NOT EXISTS verdict_message TEXT,
+    ADD COLUMN IF NOT EXISTS instance TEXT;
+
+-- commands stored before statuses were introduced are done if they have
+-- exit code
+UPDATE statuses
+SET status = CASE WHEN exit_code = -1 THEN 'interrupted' ELSE 'finished' END
+WHERE status = 'created' AND exit_code IS NOT NULL;
+
+CREATE TABLE IF NOT EXISTS artifacts (
+    id INTEGER REFERENCES commands (id),
+    name TEXT NOT NULL,
+    key TEXT NOT NULL,
+    size BIGINT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS pipeline_steps (
+    pipeline_id INTEGER NOT NULL REFERENCES pipelines (id),
+    position INTEGER NOT NULL,
+    name TEXT NOT NULL,
+    depends_on TEXT ARRAY,
+    condition TEXT NOT NULL,
+    spec JSONB NOT NULL,
+    status TEXT NOT NULL DEFAULT 'pending',
+    command_id INTEGER REFERENCES commands (id),
+    failure_message TEXT,
+    PRIMARY KEY (pipeline_id, name)
+);
+
+CREATE TABLE IF NOT EXISTS events (
+    id SERIAL PRIMARY KEY,
+    command_id INTEGER NOT NULL REFERENCES commands (id),
+    type TEXT NOT NULL,
+    data JSONB,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS events_command_id_idx ON events (command_id);
+
+CREATE TABLE IF NOT EXISTS stdin_chunks (
+    id SERIAL PRIMARY KEY,
+    command_id INTEGER NOT NULL REFERENCES commands (id),
+    data BYTEA NOT NULL,
+    remote_addr TEXT,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
 );
 
 CREATE OR REPLACE FUNCTION outputs_statuses_trigger_fnc()
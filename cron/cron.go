@@ -0,0 +1,226 @@
+package cron
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Fire times are searched at most this far, so impossible expressions
+// like "0 0 30 2 *" don't loop forever
+const searchLimit = 5 * 366 * 24 * time.Hour
+
+// Shortcuts for frequently used expressions
+var macros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+var monthNames = []string{"JAN", "FEB", "MAR", "APR", "MAY", "JUN", "JUL", "AUG", "SEP", "OCT", "NOV", "DEC"}
+
+var weekdayNames = []string{"SUN", "MON", "TUE", "WED", "THU", "FRI", "SAT"}
+
+// Range of values allowed in the field of the expression.
+type field struct {
+	name     string
+	min, max int
+
+	// names of the values starting from min
+	names []string
+}
+
+var (
+	minutesField  = field{name: "minute", min: 0, max: 59}
+	hoursField    = field{name: "hour", min: 0, max: 23}
+	daysField     = field{name: "day of month", min: 1, max: 31}
+	monthsField   = field{name: "month", min: 1, max: 12, names: monthNames}
+	weekdaysField = field{name: "day of week", min: 0, max: 7, names: weekdayNames}
+)
+
+// Parsed cron expression with five fields: minute, hour, day of month,
+// month and day of week. Every field is a set of values stored as bits.
+type Schedule struct {
+	minutes  uint64
+	hours    uint64
+	days     uint64
+	months   uint64
+	weekdays uint64
+
+	// if both day fields are restricted, day matches any of them
+	daysRestricted     bool
+	weekdaysRestricted bool
+
+	location *time.Location
+}
+
+// Parses cron expression in the location. Fields support "*", lists,
+// ranges, steps and names of months and days of week. Macros like
+// "@daily" are supported too.
+func Parse(expression string, location *time.Location) (*Schedule, error) {
+	if location == nil {
+		location = time.UTC
+	}
+
+	expression = strings.TrimSpace(expression)
+	if macro, exists := macros[strings.ToLower(expression)]; exists {
+		expression = macro
+	}
+
+	fields := strings.Fields(expression)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("cron expression must have 5 fields, got %d", len(fields))
+	}
+
+	schedule := &Schedule{location: location}
+
+	var err error
+	if schedule.minutes, err = parseField(fields[0], minutesField); err != nil {
+		return nil, err
+	}
+	if schedule.hours, err = parseField(fields[1], hoursField); err != nil {
+		return nil, err
+	}
+	if schedule.days, err = parseField(fields[2], daysField); err != nil {
+		return nil, err
+	}
+	if schedule.months, err = parseField(fields[3], monthsField); err != nil {
+		return nil, err
+	}
+	if schedule.weekdays, err = parseField(fields[4], weekdaysField); err != nil {
+		return nil, err
+	}
+
+	// 7 is another name of sunday
+	if schedule.weekdays&(1<<7) != 0 {
+		schedule.weekdays |= 1
+	}
+
+	schedule.daysRestricted = !strings.HasPrefix(fields[2], "*")
+	schedule.weekdaysRestricted = !strings.HasPrefix(fields[4], "*")
+
+	return schedule, nil
+}
+
+// Returns the first fire time strictly after provided time or zero time
+// if there is no such time in the next five years.
+func (schedule *Schedule) Next(after time.Time) time.Time {
+	t := after.In(schedule.location)
+	t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute()+1, 0, 0, schedule.location)
+	limit := t.Add(searchLimit)
+
+	for t.Before(limit) {
+		switch {
+		case !has(schedule.months, int(t.Month())):
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, schedule.location)
+		case !schedule.matchesDay(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, schedule.location)
+		case !has(schedule.hours, t.Hour()):
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, schedule.location)
+		case !has(schedule.minutes, t.Minute()):
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+
+	return time.Time{}
+}
+
+func (schedule *Schedule) matchesDay(t time.Time) bool {
+	day := has(schedule.days, t.Day())
+	weekday := has(schedule.weekdays, int(t.Weekday()))
+
+	if schedule.daysRestricted && schedule.weekdaysRestricted {
+		return day || weekday
+	}
+
+	return day && weekday
+}
+
+func has(set uint64, value int) bool {
+	return set&(1<<value) != 0
+}
+
+// Parses comma separated list of ranges into set of values.
+func parseField(value string, f field) (uint64, error) {
+	var set uint64
+
+	for _, item := range strings.Split(value, ",") {
+		first, last, step, err := parseRange(item, f)
+		if err != nil {
+			return 0, fmt.Errorf("invalid %s \"%s\": %w", f.name, item, err)
+		}
+
+		for i := first; i <= last; i += step {
+			set |= 1 << i
+		}
+	}
+
+	return set, nil
+}
+
+// Parses "*", "value", "first-last" with optional "/step".
+func parseRange(item string, f field) (int, int, int, error) {
+	rangePart, stepPart, hasStep := strings.Cut(item, "/")
+
+	step := 1
+	if hasStep {
+		var err error
+		step, err = strconv.Atoi(stepPart)
+		if err != nil || step <= 0 {
+			return 0, 0, 0, fmt.Errorf("step must be positive number")
+		}
+	}
+
+	if rangePart == "*" {
+		return f.min, f.max, step, nil
+	}
+
+	firstPart, lastPart, isRange := strings.Cut(rangePart, "-")
+
+	first, err := parseValue(firstPart, f)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+
+	last := first
+	switch {
+	case isRange:
+		last, err = parseValue(lastPart, f)
+		if err != nil {
+			return 0, 0, 0, err
+		}
+		if last < first {
+			return 0, 0, 0, fmt.Errorf("range must be ascending")
+		}
+	case hasStep:
+		// "value/step" means from value till the end
+		last = f.max
+	}
+
+	return first, last, step, nil
+}
+
+func parseValue(value string, f field) (int, error) {
+	for i, name := range f.names {
+		if strings.EqualFold(value, name) {
+			return f.min + i, nil
+		}
+	}
+
+	number, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("\"%s\" is not a number", value)
+	}
+	if number < f.min || number > f.max {
+		return 0, fmt.Errorf("value must be in range %d-%d", f.min, f.max)
+	}
+
+	return number, nil
+}
@@ -0,0 +1,83 @@
+package cron
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseInvalid(t *testing.T) {
+	invalid := []string{
+		"",
+		"* * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"*/0 * * * *",
+		"5-1 * * * *",
+		"amogus * * * *",
+		"@sometimes",
+	}
+
+	for _, expression := range invalid {
+		if _, err := Parse(expression, time.UTC); err == nil {
+			t.Fatalf("expression \"%s\" must be rejected", expression)
+		}
+	}
+}
+
+func TestNext(t *testing.T) {
+	start := time.Date(2024, time.May, 31, 23, 58, 30, 0, time.UTC) // friday
+
+	cases := []struct {
+		expression string
+		expected   time.Time
+	}{
+		{"* * * * *", time.Date(2024, time.May, 31, 23, 59, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2024, time.June, 1, 0, 0, 0, 0, time.UTC)},
+		{"30 9 * * MON-FRI", time.Date(2024, time.June, 3, 9, 30, 0, 0, time.UTC)},
+		{"0 12 1,15 * *", time.Date(2024, time.June, 1, 12, 0, 0, 0, time.UTC)},
+		{"0 0 * jan *", time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2024, time.June, 2, 0, 0, 0, 0, time.UTC)},
+		{"0 0 13 * 5", time.Date(2024, time.June, 7, 0, 0, 0, 0, time.UTC)},
+		{"@monthly", time.Date(2024, time.June, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2028, time.February, 29, 0, 0, 0, 0, time.UTC)},
+	}
+
+	for _, c := range cases {
+		schedule, err := Parse(c.expression, time.UTC)
+		if err != nil {
+			t.Fatalf("expression \"%s\" must be parsed, got \"%s\"", c.expression, err)
+		}
+
+		if next := schedule.Next(start); !next.Equal(c.expected) {
+			t.Fatalf("next time of \"%s\" must be %s, got %s", c.expression, c.expected, next)
+		}
+	}
+}
+
+func TestNextImpossible(t *testing.T) {
+	schedule, err := Parse("0 0 30 2 *", time.UTC)
+	if err != nil {
+		t.Fatalf("expression must be parsed, got \"%s\"", err)
+	}
+
+	if next := schedule.Next(time.Now()); !next.IsZero() {
+		t.Fatalf("impossible expression must not have next time, got %s", next)
+	}
+}
+
+func TestNextLocation(t *testing.T) {
+	location := time.FixedZone("UTC+3", 3*60*60)
+
+	schedule, err := Parse("0 9 * * *", location)
+	if err != nil {
+		t.Fatalf("expression must be parsed, got \"%s\"", err)
+	}
+
+	next := schedule.Next(time.Date(2024, time.June, 1, 7, 0, 0, 0, time.UTC))
+	if expected := time.Date(2024, time.June, 2, 6, 0, 0, 0, time.UTC); !next.Equal(expected) {
+		t.Fatalf("next time must be %s, got %s", expected, next)
+	}
+}
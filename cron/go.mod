@@ -0,0 +1,3 @@
+module cron
+
+go 1.22.2
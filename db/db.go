@@ -3,9 +3,13 @@ package db
 import (
 	"context"
 	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"executor"
 	"fmt"
 	"time"
+	"unicode/utf8"
 
 	pq "github.com/lib/pq"
 )
@@ -22,6 +26,65 @@ const (
 
 const defaultTimeout = time.Second * 30
 
+// Code of postgresql error that is raised on unique constraint violation
+const uniqueViolationCode pq.ErrorCode = "23505"
+
+// Error that is returned when command with same idempotency key is already
+// stored.
+var ErrDuplicateIdempotencyKey = errors.New("idempotency key is already used")
+
+// Lifecycle statuses of the command
+const (
+	// command is delayed until its launch time
+	StatusScheduled string = "scheduled"
+	// command is stored, but not launched yet
+	StatusCreated string = "created"
+	// command is launched and isn't finished yet
+	StatusRunning string = "running"
+	// processes of the running command are stopped until it's resumed
+	StatusPaused string = "paused"
+	// command is finished by itself with any exit code
+	StatusFinished string = "finished"
+	// command is killed with a signal, for example it was cancelled
+	StatusInterrupted string = "interrupted"
+	// command isn't started or its streams failed
+	StatusFailed string = "failed"
+)
+
+// Checks whether command with such status will never change it.
+func IsTerminalStatus(status string) bool {
+	return status == StatusFinished || status == StatusInterrupted || status == StatusFailed
+}
+
+// Types of the events in the command's history
+const (
+	// command is stored
+	EventCreated string = "created"
+	// delayed command waits for its launch time
+	EventQueued string = "queued"
+	// process of the command is started
+	EventStarted string = "started"
+	// outputs of the running command are stored
+	EventOutputFlushed string = "output_flushed"
+	// signal is sent to the running command
+	EventSignal string = "signal"
+	// cancellation of the command is requested
+	EventCancelRequested string = "cancel_requested"
+	// command got its final status
+	EventFinished string = "finished"
+	// command is paused and resumed
+	EventPaused  string = "paused"
+	EventResumed string = "resumed"
+	// open stdin of the command is closed
+	EventStdinClosed string = "stdin_closed"
+)
+
+// Encodings of the command's streams in JSON
+const (
+	EncodingUtf8   string = "utf8"
+	EncodingBase64 string = "base64"
+)
+
 // Connection credentials.
 //
 // If anything is empty or 0 - it will be replaced with
@@ -80,9 +143,12 @@ func (connection *Connection) Close() error {
 
 // Returns an array of command strings stored in the database.
 func (connection *Connection) GetCommands() ([]CommandTableRecord, error) {
+	ctx, cancel := createTimeoutDefaultContext()
+	defer cancel()
+
 	rows, err := connection.db.QueryContext(
-		createTimeoutDefaultContext(),
-		`SELECT * FROM commands`,
+		ctx,
+		`SELECT id, command FROM commands ORDER BY id`,
 	)
 	if err != nil {
 		return nil, err
@@ -106,15 +172,142 @@ func (connection *Connection) GetCommands() ([]CommandTableRecord, error) {
 	return records, nil
 }
 
+// Returns commands of the batch with their statuses.
+func (connection *Connection) GetBatchCommands(batchId uint64) ([]CommandSummaryRecord, error) {
+	return connection.getCommandSummaries(`WHERE c.batch_id = $1`, batchId)
+}
+
+// Returns commands of the named group with their statuses.
+func (connection *Connection) GetGroupCommands(group string) ([]CommandSummaryRecord, error) {
+	return connection.getCommandSummaries(`WHERE c.group_name = $1`, group)
+}
+
+// Returns commands launched by the schedule with their statuses.
+func (connection *Connection) GetScheduleCommands(scheduleId uint64) ([]CommandSummaryRecord, error) {
+	return connection.getCommandSummaries(`WHERE c.schedule_id = $1`, scheduleId)
+}
+
+// Returns attempts of the command launched with retry policy.
+func (connection *Connection) GetAttempts(parentId uint64) ([]CommandSummaryRecord, error) {
+	return connection.getCommandSummaries(`WHERE c.parent_id = $1`, parentId)
+}
+
+// Returns commands that were launched as re-runs of the command.
+func (connection *Connection) GetReruns(originId uint64) ([]CommandSummaryRecord, error) {
+	return connection.getCommandSummaries(`WHERE c.rerun_of = $1`, originId)
+}
+
+// Returns commands that match provided condition with their statuses.
+func (connection *Connection) getCommandSummaries(condition string, args ...any) ([]CommandSummaryRecord, error) {
+	ctx, cancel := createTimeoutDefaultContext()
+	defer cancel()
+
+	rows, err := connection.db.QueryContext(
+		ctx,
+		`
+			SELECT
+				c.id, c.command, c.batch_id, c.group_name, c.schedule_id, c.parent_id, c.attempt, c.rerun_of, c.pipeline_id,
+				s.status, s.exit_code, s.verdict
+			FROM commands AS c
+			JOIN statuses AS s ON c.id = s.id
+		`+condition+`
+			ORDER BY c.id
+		`,
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var records []CommandSummaryRecord
+	for rows.Next() {
+		var record CommandSummaryRecord
+
+		nullableBatchId := sql.NullInt64{}
+		nullableGroup := sql.NullString{}
+		nullableScheduleId := sql.NullInt64{}
+		nullableParentId := sql.NullInt64{}
+		nullableAttempt := sql.NullInt32{}
+		nullableRerunOf := sql.NullInt64{}
+		nullablePipelineId := sql.NullInt64{}
+		nullableStatus := sql.NullString{}
+		nullableExitCode := sql.NullInt32{}
+		nullableVerdict := sql.NullString{}
+
+		err := rows.Scan(
+			&record.Command.Id,
+			&record.Command.Command,
+			&nullableBatchId,
+			&nullableGroup,
+			&nullableScheduleId,
+			&nullableParentId,
+			&nullableAttempt,
+			&nullableRerunOf,
+			&nullablePipelineId,
+			&nullableStatus,
+			&nullableExitCode,
+			&nullableVerdict,
+		)
+		if err != nil {
+			return records, err
+		}
+
+		record.Command.BatchId = uint64(nullableBatchId.Int64)
+		record.Command.Group = nullableGroup.String
+		record.Command.ScheduleId = uint64(nullableScheduleId.Int64)
+		record.Command.ParentId = uint64(nullableParentId.Int64)
+		record.Command.Attempt = int(nullableAttempt.Int32)
+		record.Command.RerunOf = uint64(nullableRerunOf.Int64)
+		record.Command.PipelineId = uint64(nullablePipelineId.Int64)
+		record.Statuses.Status = nullableStatus.String
+		if nullableExitCode.Valid {
+			record.Statuses.ExitCode = int(nullableExitCode.Int32)
+		} else {
+			record.Statuses.ExitCode = -2
+		}
+		record.Statuses.Verdict = nullableVerdict.String
+		record.Statuses.id = record.Command.Id
+
+		records = append(records, record)
+	}
+
+	return records, rows.Err()
+}
+
+// Returns ID and request hash of the command launched with provided
+// idempotency key.
+func (connection *Connection) GetIdempotentCommand(key string) (uint64, string, error) {
+	ctx, cancel := createTimeoutDefaultContext()
+	defer cancel()
+
+	var id uint64
+	var requestSha256 string
+	err := connection.db.QueryRowContext(
+		ctx,
+		`SELECT id, request_sha256 FROM commands WHERE idempotency_key = $1`,
+		key,
+	).Scan(&id, &requestSha256)
+
+	return id, requestSha256, err
+}
+
 // Returns fully populated data of launched or finished command that stores
 // in the database.
 func (connection *Connection) GetFullRecordById(recordId uint64) (FullCommandRecord, error) {
 	var record FullCommandRecord
 
+	ctx, cancel := createTimeoutDefaultContext()
+	defer cancel()
+
 	row := connection.db.QueryRowContext(
-		createTimeoutDefaultContext(),
+		ctx,
 		`
-			SELECT c.command, i.input, i.env, o.output, o.errors, s.exit_code
+			SELECT
+				c.command, c.idempotency_key, c.batch_id, c.group_name, c.schedule_id, c.run_at, c.parent_id, c.attempt, c.rerun_of, c.pipeline_id, i.workdir, i.input, i.env, i.input_size, i.input_sha256, i.artifacts, i.stdin_from, i.env_from, i.timeout_seconds, i.retry, i.success,
+				o.output, o.errors, o.output_key, o.output_size, o.errors_key, o.errors_size,
+				o.output_partial, o.errors_partial,
+				s.status, s.exit_code, s.failure_class, s.failure_message, s.verdict, s.verdict_message
 			FROM commands AS c
 			JOIN inputs AS i ON c.id = i.id
 			JOIN outputs AS o ON c.id = o.id
@@ -124,187 +317,1718 @@ func (connection *Connection) GetFullRecordById(recordId uint64) (FullCommandRec
 		recordId,
 	)
 
+	nullableIdempotencyKey := sql.NullString{}
+	nullableBatchId := sql.NullInt64{}
+	nullableGroup := sql.NullString{}
+	nullableScheduleId := sql.NullInt64{}
+	nullableParentId := sql.NullInt64{}
+	nullableAttempt := sql.NullInt32{}
+	nullableRerunOf := sql.NullInt64{}
+	nullablePipelineId := sql.NullInt64{}
+	nullableTimeoutSeconds := sql.NullFloat64{}
+	var retry, success []byte
+	nullableWorkdir := sql.NullString{}
 	nullableInput := sql.NullString{}
-	nullableOutput := sql.NullString{}
-	nullableErrors := sql.NullString{}
+	nullableInputSize := sql.NullInt64{}
+	nullableInputSha256 := sql.NullString{}
+	nullableStdinFrom := sql.NullInt64{}
+	var envFrom []byte
+	nullableOutputKey := sql.NullString{}
+	nullableOutputSize := sql.NullInt64{}
+	nullableErrorsKey := sql.NullString{}
+	nullableErrorsSize := sql.NullInt64{}
+	nullableOutputPartial := sql.NullString{}
+	nullableErrorsPartial := sql.NullString{}
+	nullableStatus := sql.NullString{}
 	nullableExitCode := sql.NullInt32{}
+	nullableFailureClass := sql.NullString{}
+	nullableFailureMessage := sql.NullString{}
+	nullableVerdict := sql.NullString{}
+	nullableVerdictMessage := sql.NullString{}
 
 	err := row.Scan(
 		&record.Command.Command,
+		&nullableIdempotencyKey,
+		&nullableBatchId,
+		&nullableGroup,
+		&nullableScheduleId,
+		&record.Command.RunAt,
+		&nullableParentId,
+		&nullableAttempt,
+		&nullableRerunOf,
+		&nullablePipelineId,
+		&nullableWorkdir,
 		&nullableInput,
 		pq.Array(&record.Input.Env),
-		&nullableOutput,
-		&nullableErrors,
+		&nullableInputSize,
+		&nullableInputSha256,
+		pq.Array(&record.Input.Artifacts),
+		&nullableStdinFrom,
+		&envFrom,
+		&nullableTimeoutSeconds,
+		&retry,
+		&success,
+		&record.Outputs.Output,
+		&record.Outputs.Errors,
+		&nullableOutputKey,
+		&nullableOutputSize,
+		&nullableErrorsKey,
+		&nullableErrorsSize,
+		&nullableOutputPartial,
+		&nullableErrorsPartial,
+		&nullableStatus,
 		&nullableExitCode,
+		&nullableFailureClass,
+		&nullableFailureMessage,
+		&nullableVerdict,
+		&nullableVerdictMessage,
 	)
+	record.Command.IdempotencyKey = nullableIdempotencyKey.String
+	record.Command.BatchId = uint64(nullableBatchId.Int64)
+	record.Command.Group = nullableGroup.String
+	record.Command.ScheduleId = uint64(nullableScheduleId.Int64)
+	record.Command.ParentId = uint64(nullableParentId.Int64)
+	record.Command.Attempt = int(nullableAttempt.Int32)
+	record.Command.RerunOf = uint64(nullableRerunOf.Int64)
+	record.Command.PipelineId = uint64(nullablePipelineId.Int64)
+	record.Input.Workdir = nullableWorkdir.String
 	record.Input.Input = nullableInput.String
-	record.Outputs.Output = nullableOutput.String
-	record.Outputs.Errors = nullableErrors.String
+	record.Input.InputSize = nullableInputSize.Int64
+	record.Input.InputSha256 = nullableInputSha256.String
+	record.Input.StdinFrom = uint64(nullableStdinFrom.Int64)
+	record.Input.TimeoutSeconds = nullableTimeoutSeconds.Float64
+	record.Input.Retry = retry
+	record.Input.Success = success
+	record.Outputs.OutputKey = nullableOutputKey.String
+	record.Outputs.OutputSize = nullableOutputSize.Int64
+	record.Outputs.ErrorsKey = nullableErrorsKey.String
+	record.Outputs.ErrorsSize = nullableErrorsSize.Int64
+	record.Outputs.OutputPartial = nullableOutputPartial.String
+	record.Outputs.ErrorsPartial = nullableErrorsPartial.String
 	if nullableExitCode.Valid {
 		record.Statuses.ExitCode = int(nullableExitCode.Int32)
 	} else {
 		record.Statuses.ExitCode = -2
 	}
+	record.Statuses.Status = nullableStatus.String
+	record.Statuses.FailureClass = nullableFailureClass.String
+	record.Statuses.FailureMessage = nullableFailureMessage.String
+	record.Statuses.Verdict = nullableVerdict.String
+	record.Statuses.VerdictMessage = nullableVerdictMessage.String
 
 	record.Command.Id = recordId
 	record.Input.id = record.Command.Id
 	record.Outputs.id = record.Command.Id
 	record.Statuses.id = record.Command.Id
 
-	return record, err
-}
+	if err != nil {
+		return record, err
+	}
 
-// Pushes command and its inputs into the database.
-func (connection *Connection) InsertRecord(command CommandTableRecord, input InputTableRecord) (uint64, error) {
-	tx, err := connection.db.BeginTx(createTimeoutDefaultContext(), nil)
+	if envFrom != nil {
+		if err := json.Unmarshal(envFrom, &record.Input.EnvFrom); err != nil {
+			return record, err
+		}
+	}
+
+	record.Input.Files, err = connection.getInputFiles(ctx, recordId)
 	if err != nil {
-		return 0, err
+		return record, err
 	}
 
-	row := tx.QueryRowContext(
-		createTimeoutDefaultContext(),
-		`INSERT INTO commands (command) VALUES ($1) RETURNING id`,
-		command.Command,
-	)
-	err = row.Scan(&command.Id)
+	record.Input.Workspace, err = connection.getWorkspace(ctx, recordId)
 	if err != nil {
-		tx.Rollback()
-		return command.Id, err
+		return record, err
 	}
 
-	_, err = tx.ExecContext(
-		createTimeoutDefaultContext(),
-		`INSERT INTO inputs VALUES ($1, $2, $3)`,
-		command.Id,
-		input.Input,
-		pq.Array(input.Env),
-	)
+	record.Artifacts, err = connection.getArtifacts(ctx, recordId)
 	if err != nil {
-		tx.Rollback()
-		return command.Id, err
+		return record, err
+	}
+
+	if record.Input.Retry != nil {
+		record.Attempts, err = connection.GetAttempts(recordId)
+		if err != nil {
+			return record, err
+		}
 	}
 
-	return command.Id, tx.Commit()
+	record.Reruns, err = connection.GetReruns(recordId)
+	return record, err
 }
 
-// Updates launched command's outputs and statuses.
-func (connection *Connection) UpdateRecord(
-	recordId uint64,
-	outputs *OutputsTableRecord,
-	statuses StatusesTableRecord,
-) error {
-	tx, err := connection.db.BeginTx(createTimeoutDefaultContext(), nil)
+func (connection *Connection) getWorkspace(ctx context.Context, recordId uint64) (*WorkspaceTableRecord, error) {
+	record := &WorkspaceTableRecord{id: recordId}
+
+	nullableTemplate := sql.NullString{}
+	nullableExpiresAt := sql.NullTime{}
+	nullableRemovedAt := sql.NullTime{}
+
+	err := connection.db.QueryRowContext(
+		ctx,
+		`
+			SELECT path, template, cleanup, retain_hours, expires_at, removed_at
+			FROM workspaces
+			WHERE id = $1
+		`,
+		recordId,
+	).Scan(
+		&record.Path,
+		&nullableTemplate,
+		&record.Cleanup,
+		&record.RetainHours,
+		&nullableExpiresAt,
+		&nullableRemovedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	output, errors := sql.NullString{}, sql.NullString{}
-	if outputs.Output != "" {
-		output.String = outputs.Output
-		output.Valid = true
+	record.Template = nullableTemplate.String
+	if nullableExpiresAt.Valid {
+		record.ExpiresAt = &nullableExpiresAt.Time
 	}
-	if outputs.Errors != "" {
-		errors.String = outputs.Errors
-		errors.Valid = true
+	if nullableRemovedAt.Valid {
+		record.RemovedAt = &nullableRemovedAt.Time
 	}
 
-	_, err = tx.ExecContext(
-		createTimeoutDefaultContext(),
+	return record, nil
+}
+
+// Returns workspaces that are not removed yet and retention time of which
+// is passed.
+func (connection *Connection) GetExpiredWorkspaces() ([]WorkspaceTableRecord, error) {
+	ctx, cancel := createTimeoutDefaultContext()
+	defer cancel()
+
+	rows, err := connection.db.QueryContext(
+		ctx,
 		`
-			UPDATE outputs SET output = $2, errors = $3
-			WHERE id = $1
+			SELECT id, path FROM workspaces
+			WHERE removed_at IS NULL AND expires_at < now()
 		`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var records []WorkspaceTableRecord
+	for rows.Next() {
+		var record WorkspaceTableRecord
+		if err := rows.Scan(&record.id, &record.Path); err != nil {
+			return records, err
+		}
+
+		records = append(records, record)
+	}
+
+	return records, rows.Err()
+}
+
+// Sets time after which workspace of the command must be removed.
+func (connection *Connection) SetWorkspaceExpiration(recordId uint64, expiresAt time.Time) error {
+	ctx, cancel := createTimeoutDefaultContext()
+	defer cancel()
+
+	_, err := connection.db.ExecContext(
+		ctx,
+		`UPDATE workspaces SET expires_at = $2 WHERE id = $1`,
+		recordId,
+		expiresAt,
+	)
+
+	return err
+}
+
+// Marks workspace of the command as removed.
+func (connection *Connection) SetWorkspaceRemoved(recordId uint64) error {
+	ctx, cancel := createTimeoutDefaultContext()
+	defer cancel()
+
+	_, err := connection.db.ExecContext(
+		ctx,
+		`UPDATE workspaces SET removed_at = now() WHERE id = $1`,
+		recordId,
+	)
+
+	return err
+}
+
+func (connection *Connection) getInputFiles(ctx context.Context, recordId uint64) ([]InputFileTableRecord, error) {
+	rows, err := connection.db.QueryContext(
+		ctx,
+		`SELECT path, mode, size, sha256 FROM input_files WHERE id = $1 ORDER BY path`,
 		recordId,
-		output,
-		errors,
 	)
 	if err != nil {
-		tx.Rollback()
-		return err
+		return nil, err
 	}
+	defer rows.Close()
 
-	exitCode := sql.NullInt32{}
-	if statuses.ExitCode != -2 {
-		exitCode.Int32 = int32(statuses.ExitCode)
-		exitCode.Valid = true
+	var records []InputFileTableRecord
+	for rows.Next() {
+		record := InputFileTableRecord{id: recordId}
+		err := rows.Scan(
+			&record.Path,
+			&record.Mode,
+			&record.Size,
+			&record.Sha256,
+		)
+		if err != nil {
+			return records, err
+		}
+
+		records = append(records, record)
 	}
 
-	_, err = tx.ExecContext(
-		createTimeoutDefaultContext(),
+	return records, rows.Err()
+}
+
+// Returns outputs of launched or finished command that stores in the
+// database.
+func (connection *Connection) GetOutputsById(recordId uint64) (OutputsTableRecord, error) {
+	var record OutputsTableRecord
+
+	ctx, cancel := createTimeoutDefaultContext()
+	defer cancel()
+
+	row := connection.db.QueryRowContext(
+		ctx,
 		`
-			UPDATE statuses SET exit_code = $2
+			SELECT output, errors, output_key, output_size, errors_key, errors_size, output_partial, errors_partial
+			FROM outputs
 			WHERE id = $1
 		`,
 		recordId,
-		exitCode,
+	)
+
+	nullableOutputKey := sql.NullString{}
+	nullableOutputSize := sql.NullInt64{}
+	nullableErrorsKey := sql.NullString{}
+	nullableErrorsSize := sql.NullInt64{}
+	nullableOutputPartial := sql.NullString{}
+	nullableErrorsPartial := sql.NullString{}
+
+	err := row.Scan(
+		&record.Output,
+		&record.Errors,
+		&nullableOutputKey,
+		&nullableOutputSize,
+		&nullableErrorsKey,
+		&nullableErrorsSize,
+		&nullableOutputPartial,
+		&nullableErrorsPartial,
+	)
+	record.OutputKey = nullableOutputKey.String
+	record.OutputSize = nullableOutputSize.Int64
+	record.ErrorsKey = nullableErrorsKey.String
+	record.ErrorsSize = nullableErrorsSize.Int64
+	record.OutputPartial = nullableOutputPartial.String
+	record.ErrorsPartial = nullableErrorsPartial.String
+	record.id = recordId
+
+	return record, err
+}
+
+// Returns artifact of the command with provided name.
+func (connection *Connection) GetArtifact(recordId uint64, name string) (ArtifactTableRecord, error) {
+	record := ArtifactTableRecord{id: recordId, Name: name}
+
+	ctx, cancel := createTimeoutDefaultContext()
+	defer cancel()
+
+	err := connection.db.QueryRowContext(
+		ctx,
+		`SELECT key, size FROM artifacts WHERE id = $1 AND name = $2`,
+		recordId,
+		name,
+	).Scan(&record.Key, &record.Size)
+
+	return record, err
+}
+
+func (connection *Connection) getArtifacts(ctx context.Context, recordId uint64) ([]ArtifactTableRecord, error) {
+	rows, err := connection.db.QueryContext(
+		ctx,
+		`SELECT name, key, size FROM artifacts WHERE id = $1 ORDER BY name`,
+		recordId,
 	)
 	if err != nil {
-		tx.Rollback()
+		return nil, err
+	}
+	defer rows.Close()
+
+	var records []ArtifactTableRecord
+	for rows.Next() {
+		record := ArtifactTableRecord{id: recordId}
+		err := rows.Scan(
+			&record.Name,
+			&record.Key,
+			&record.Size,
+		)
+		if err != nil {
+			return records, err
+		}
+
+		records = append(records, record)
+	}
+
+	return records, rows.Err()
+}
+
+// Pushes artifacts collected after command's finish into the database.
+func (connection *Connection) InsertArtifacts(recordId uint64, artifacts []ArtifactTableRecord) error {
+	ctx, cancel := createTimeoutDefaultContext()
+	defer cancel()
+
+	tx, err := connection.db.BeginTx(ctx, nil)
+	if err != nil {
 		return err
 	}
 
+	for _, artifact := range artifacts {
+		_, err = tx.ExecContext(
+			ctx,
+			`INSERT INTO artifacts (id, name, key, size) VALUES ($1, $2, $3, $4)`,
+			recordId,
+			artifact.Name,
+			artifact.Key,
+			artifact.Size,
+		)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
 	return tx.Commit()
 }
 
-// Struct that represents essential command info in the "commands" table.
-type CommandTableRecord struct {
-	Id uint64 `json:"id"`
+// Appends event into the history of the command.
+func (connection *Connection) InsertEvent(recordId uint64, event EventTableRecord) error {
+	ctx, cancel := createTimeoutDefaultContext()
+	defer cancel()
 
-	Command string `json:"command"`
+	var data any
+	if len(event.Data) != 0 {
+		data = event.Data
+	}
+
+	return insertEvent(ctx, connection.db, recordId, event.Type, data)
 }
 
-// Struct that represents command's inputs in the "inputs" table.
-type InputTableRecord struct {
-	id uint64
+// Returns history of the command in order of events.
+func (connection *Connection) GetEvents(recordId uint64) ([]EventTableRecord, error) {
+	ctx, cancel := createTimeoutDefaultContext()
+	defer cancel()
 
-	Input string                      `json:"input"`
-	Env   []executor.EnvironmentEntry `json:"env"`
-}
+	rows, err := connection.db.QueryContext(
+		ctx,
+		`SELECT id, type, data, created_at FROM events WHERE command_id = $1 ORDER BY id`,
+		recordId,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
 
-// Struct that represents command's outputs in the "outputs" table.
-type OutputsTableRecord struct {
-	id uint64
+	events := make([]EventTableRecord, 0)
+	for rows.Next() {
+		event := EventTableRecord{commandId: recordId}
 
-	Output string `json:"output"`
-	Errors string `json:"errors"`
-}
+		var data []byte
+		if err := rows.Scan(&event.Id, &event.Type, &data, &event.CreatedAt); err != nil {
+			return events, err
+		}
+		event.Data = data
 
-// Struct that represents command's results in the "statuses" table.
-type StatusesTableRecord struct {
-	id uint64
+		events = append(events, event)
+	}
 
-	ExitCode int `json:"exit_code"`
+	return events, rows.Err()
 }
 
-// Struct that stores full command info.
-type FullCommandRecord struct {
-	Command  CommandTableRecord  `json:"command_info"`
-	Input    InputTableRecord    `json:"input_info"`
-	Outputs  OutputsTableRecord  `json:"outputs"`
-	Statuses StatusesTableRecord `json:"statuses"`
+// Something that executes queries: either connection or transaction.
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
 }
 
-func checkDefaultCredentials(credentials *Credentials) {
-	if credentials.Username == "" {
-		credentials.Username = DefaultUsername
-	}
-	if credentials.Password == "" {
-		credentials.Password = DefaultPassword
-	}
-	if credentials.Host == "" {
-		credentials.Host = DefaultHost
-	}
-	if credentials.Port == 0 {
-		credentials.Port = DefaultPort
+// Appends event with data encoded into JSON into the history of the
+// command.
+func insertEvent(ctx context.Context, conn execer, recordId uint64, eventType string, data any) error {
+	var encoded []byte
+	if data != nil {
+		var err error
+		encoded, err = json.Marshal(data)
+		if err != nil {
+			return err
+		}
 	}
+
+	_, err := conn.ExecContext(
+		ctx,
+		`INSERT INTO events (command_id, type, data) VALUES ($1, $2, $3)`,
+		recordId,
+		eventType,
+		nullableJson(encoded),
+	)
+	return err
 }
 
-func createTimeoutDefaultContext() context.Context {
-	ctx, _ := context.WithTimeoutCause(
-		context.Background(),
-		defaultTimeout,
-		fmt.Errorf("operation timed out"),
+// Stores chunk of data written into the open stdin of the command.
+func (connection *Connection) InsertStdinChunk(recordId uint64, chunk StdinChunkTableRecord) error {
+	ctx, cancel := createTimeoutDefaultContext()
+	defer cancel()
+
+	_, err := connection.db.ExecContext(
+		ctx,
+		`INSERT INTO stdin_chunks (command_id, data, remote_addr) VALUES ($1, $2, $3)`,
+		recordId,
+		chunk.Data,
+		chunk.RemoteAddr,
 	)
+	return err
+}
 
-	return ctx
+// Pushes command and its inputs into the database.
+func (connection *Connection) InsertRecord(command CommandTableRecord, input InputTableRecord) (uint64, error) {
+	ctx, cancel := createTimeoutDefaultContext()
+	defer cancel()
+
+	tx, err := connection.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := insertRecord(ctx, tx, command, input)
+	if err != nil {
+		tx.Rollback()
+		return id, err
+	}
+
+	return id, tx.Commit()
+}
+
+// Pushes batch of commands and their inputs into the database in one
+// transaction. Returns ID of the batch and IDs of the commands in the same
+// order.
+func (connection *Connection) InsertBatch(commands []CommandTableRecord, inputs []InputTableRecord) (uint64, []uint64, error) {
+	if len(commands) != len(inputs) {
+		return 0, nil, fmt.Errorf("amount of commands and inputs must be equal")
+	}
+
+	ctx, cancel := createTimeoutDefaultContext()
+	defer cancel()
+
+	tx, err := connection.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	var batchId uint64
+	err = tx.QueryRowContext(
+		ctx,
+		`INSERT INTO batches DEFAULT VALUES RETURNING id`,
+	).Scan(&batchId)
+	if err != nil {
+		tx.Rollback()
+		return 0, nil, err
+	}
+
+	ids := make([]uint64, 0, len(commands))
+	for i := range commands {
+		commands[i].BatchId = batchId
+
+		id, err := insertRecord(ctx, tx, commands[i], inputs[i])
+		if err != nil {
+			tx.Rollback()
+			return batchId, ids, err
+		}
+
+		ids = append(ids, id)
+	}
+
+	return batchId, ids, tx.Commit()
+}
+
+func insertRecord(ctx context.Context, tx *sql.Tx, command CommandTableRecord, input InputTableRecord) (uint64, error) {
+	idempotencyKey, requestSha256 := sql.NullString{}, sql.NullString{}
+	if command.IdempotencyKey != "" {
+		idempotencyKey.String = command.IdempotencyKey
+		idempotencyKey.Valid = true
+		requestSha256.String = command.RequestSha256
+		requestSha256.Valid = true
+	}
+
+	batchId := sql.NullInt64{}
+	if command.BatchId != 0 {
+		batchId.Int64 = int64(command.BatchId)
+		batchId.Valid = true
+	}
+
+	group := sql.NullString{String: command.Group, Valid: command.Group != ""}
+	scheduleId := sql.NullInt64{Int64: int64(command.ScheduleId), Valid: command.ScheduleId != 0}
+	parentId := sql.NullInt64{Int64: int64(command.ParentId), Valid: command.ParentId != 0}
+	attempt := sql.NullInt32{Int32: int32(command.Attempt), Valid: command.Attempt != 0}
+	rerunOf := sql.NullInt64{Int64: int64(command.RerunOf), Valid: command.RerunOf != 0}
+	pipelineId := sql.NullInt64{Int64: int64(command.PipelineId), Valid: command.PipelineId != 0}
+
+	row := tx.QueryRowContext(
+		ctx,
+		`
+			INSERT INTO commands (
+				command, idempotency_key, request_sha256, batch_id, group_name, schedule_id, run_at, parent_id, attempt,
+				rerun_of, pipeline_id
+			)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+			RETURNING id
+		`,
+		command.Command,
+		idempotencyKey,
+		requestSha256,
+		batchId,
+		group,
+		scheduleId,
+		command.RunAt,
+		parentId,
+		attempt,
+		rerunOf,
+		pipelineId,
+	)
+	err := row.Scan(&command.Id)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == uniqueViolationCode {
+			return command.Id, ErrDuplicateIdempotencyKey
+		}
+		return command.Id, err
+	}
+
+	stdinFrom := sql.NullInt64{Int64: int64(input.StdinFrom), Valid: input.StdinFrom != 0}
+	timeoutSeconds := sql.NullFloat64{Float64: input.TimeoutSeconds, Valid: input.TimeoutSeconds != 0}
+
+	var envFrom []byte
+	if len(input.EnvFrom) != 0 {
+		envFrom, err = json.Marshal(input.EnvFrom)
+		if err != nil {
+			return command.Id, err
+		}
+	}
+
+	_, err = tx.ExecContext(
+		ctx,
+		`
+			INSERT INTO inputs (
+				id, workdir, input, env, input_size, input_sha256, artifacts, stdin_from, env_from, timeout_seconds, retry,
+				success
+			)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+		`,
+		command.Id,
+		input.Workdir,
+		input.Input,
+		pq.Array(input.Env),
+		input.InputSize,
+		input.InputSha256,
+		pq.Array(input.Artifacts),
+		stdinFrom,
+		nullableJson(envFrom),
+		timeoutSeconds,
+		nullableJson(input.Retry),
+		nullableJson(input.Success),
+	)
+	if err != nil {
+		return command.Id, err
+	}
+
+	if err := insertEvent(ctx, tx, command.Id, EventCreated, nil); err != nil {
+		return command.Id, err
+	}
+
+	// delayed commands are launched by their time
+	if command.RunAt != nil {
+		_, err = tx.ExecContext(
+			ctx,
+			`UPDATE statuses SET status = $2 WHERE id = $1`,
+			command.Id,
+			StatusScheduled,
+		)
+		if err != nil {
+			return command.Id, err
+		}
+
+		err = insertEvent(ctx, tx, command.Id, EventQueued, map[string]time.Time{"run_at": *command.RunAt})
+		if err != nil {
+			return command.Id, err
+		}
+	}
+
+	if input.Workspace != nil {
+		_, err = tx.ExecContext(
+			ctx,
+			`
+				INSERT INTO workspaces (id, path, template, cleanup, retain_hours)
+				VALUES ($1, $2, $3, $4, $5)
+			`,
+			command.Id,
+			input.Workspace.Path,
+			input.Workspace.Template,
+			input.Workspace.Cleanup,
+			input.Workspace.RetainHours,
+		)
+		if err != nil {
+			return command.Id, err
+		}
+	}
+
+	for _, file := range input.Files {
+		_, err = tx.ExecContext(
+			ctx,
+			`INSERT INTO input_files (id, path, mode, size, sha256) VALUES ($1, $2, $3, $4, $5)`,
+			command.Id,
+			file.Path,
+			file.Mode,
+			file.Size,
+			file.Sha256,
+		)
+		if err != nil {
+			return command.Id, err
+		}
+	}
+
+	return command.Id, nil
+}
+
+// Updates command's input after it was fully streamed to the command.
+func (connection *Connection) UpdateInput(recordId uint64, input InputTableRecord) error {
+	ctx, cancel := createTimeoutDefaultContext()
+	defer cancel()
+
+	_, err := connection.db.ExecContext(
+		ctx,
+		`
+			UPDATE inputs SET input = $2, input_size = $3, input_sha256 = $4
+			WHERE id = $1
+		`,
+		recordId,
+		input.Input,
+		input.InputSize,
+		input.InputSha256,
+	)
+
+	return err
+}
+
+// Marks command as running by the server instance with provided name.
+func (connection *Connection) SetRunning(recordId uint64, instance string) error {
+	ctx, cancel := createTimeoutDefaultContext()
+	defer cancel()
+
+	_, err := connection.db.ExecContext(
+		ctx,
+		`UPDATE statuses SET status = $2, instance = $3 WHERE id = $1`,
+		recordId,
+		StatusRunning,
+		instance,
+	)
+
+	return err
+}
+
+// Sets provided final statuses to the commands that are left running or
+// paused by the previous process of the server instance. Returns IDs of
+// such commands.
+func (connection *Connection) InterruptOrphanedCommands(instance string, statuses StatusesTableRecord) ([]uint64, error) {
+	ctx, cancel := createTimeoutDefaultContext()
+	defer cancel()
+
+	tx, err := connection.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := tx.QueryContext(
+		ctx,
+		`
+			UPDATE statuses SET
+				status = $3, exit_code = $4,
+				failure_class = $5, failure_message = $6,
+				verdict = $7, verdict_message = $8
+			WHERE instance = $1 AND status = ANY($2)
+			RETURNING id
+		`,
+		instance,
+		pq.Array([]string{StatusRunning, StatusPaused}),
+		statuses.Status,
+		statuses.ExitCode,
+		statuses.FailureClass,
+		statuses.FailureMessage,
+		statuses.Verdict,
+		statuses.VerdictMessage,
+	)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	var ids []uint64
+	for rows.Next() {
+		var id uint64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			tx.Rollback()
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	for _, id := range ids {
+		if err := insertEvent(ctx, tx, id, EventFinished, statuses); err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+	}
+
+	return ids, tx.Commit()
+}
+
+// Changes lifecycle status of the command only if it has expected one.
+// Returns whether status is changed.
+func (connection *Connection) SwapStatus(recordId uint64, current, status string) (bool, error) {
+	ctx, cancel := createTimeoutDefaultContext()
+	defer cancel()
+
+	result, err := connection.db.ExecContext(
+		ctx,
+		`UPDATE statuses SET status = $3 WHERE id = $1 AND status = $2`,
+		recordId,
+		current,
+		status,
+	)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	return affected != 0, err
+}
+
+// Returns statuses of the command.
+func (connection *Connection) GetStatusesById(recordId uint64) (StatusesTableRecord, error) {
+	record := StatusesTableRecord{id: recordId}
+
+	ctx, cancel := createTimeoutDefaultContext()
+	defer cancel()
+
+	nullableExitCode := sql.NullInt32{}
+	nullableFailureClass := sql.NullString{}
+	nullableFailureMessage := sql.NullString{}
+	nullableVerdict := sql.NullString{}
+	nullableVerdictMessage := sql.NullString{}
+
+	err := connection.db.QueryRowContext(
+		ctx,
+		`
+			SELECT status, exit_code, failure_class, failure_message, verdict, verdict_message
+			FROM statuses
+			WHERE id = $1
+		`,
+		recordId,
+	).Scan(
+		&record.Status,
+		&nullableExitCode,
+		&nullableFailureClass,
+		&nullableFailureMessage,
+		&nullableVerdict,
+		&nullableVerdictMessage,
+	)
+
+	if nullableExitCode.Valid {
+		record.ExitCode = int(nullableExitCode.Int32)
+	} else {
+		record.ExitCode = -2
+	}
+	record.FailureClass = nullableFailureClass.String
+	record.FailureMessage = nullableFailureMessage.String
+	record.Verdict = nullableVerdict.String
+	record.VerdictMessage = nullableVerdictMessage.String
+
+	return record, err
+}
+
+// Pushes pipeline with its steps into the database. Pipeline is executed
+// by the server instance with provided name. Returns ID of the pipeline.
+func (connection *Connection) InsertPipeline(instance string, steps []PipelineStepTableRecord) (uint64, error) {
+	ctx, cancel := createTimeoutDefaultContext()
+	defer cancel()
+
+	tx, err := connection.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	var pipelineId uint64
+	err = tx.QueryRowContext(
+		ctx,
+		`INSERT INTO pipelines (instance) VALUES ($1) RETURNING id`,
+		instance,
+	).Scan(&pipelineId)
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	for position, step := range steps {
+		_, err := tx.ExecContext(
+			ctx,
+			`
+				INSERT INTO pipeline_steps (pipeline_id, position, name, depends_on, condition, spec)
+				VALUES ($1, $2, $3, $4, $5, $6)
+			`,
+			pipelineId,
+			position,
+			step.Name,
+			pq.Array(step.DependsOn),
+			step.Condition,
+			[]byte(step.Spec),
+		)
+		if err != nil {
+			tx.Rollback()
+			return pipelineId, err
+		}
+	}
+
+	return pipelineId, tx.Commit()
+}
+
+// Returns pipeline with its steps in the order they were defined.
+func (connection *Connection) GetPipeline(pipelineId uint64) (PipelineTableRecord, error) {
+	record := PipelineTableRecord{Id: pipelineId}
+
+	ctx, cancel := createTimeoutDefaultContext()
+	defer cancel()
+
+	err := connection.db.QueryRowContext(
+		ctx,
+		`SELECT status, created_at, finished_at FROM pipelines WHERE id = $1`,
+		pipelineId,
+	).Scan(&record.Status, &record.CreatedAt, &record.FinishedAt)
+	if err != nil {
+		return record, err
+	}
+
+	rows, err := connection.db.QueryContext(
+		ctx,
+		`
+			SELECT name, depends_on, condition, spec, status, command_id, failure_message
+			FROM pipeline_steps
+			WHERE pipeline_id = $1
+			ORDER BY position
+		`,
+		pipelineId,
+	)
+	if err != nil {
+		return record, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		step := PipelineStepTableRecord{pipelineId: pipelineId}
+
+		var spec []byte
+		nullableCommandId := sql.NullInt64{}
+		nullableFailureMessage := sql.NullString{}
+
+		err := rows.Scan(
+			&step.Name,
+			pq.Array(&step.DependsOn),
+			&step.Condition,
+			&spec,
+			&step.Status,
+			&nullableCommandId,
+			&nullableFailureMessage,
+		)
+		if err != nil {
+			return record, err
+		}
+
+		step.Spec = spec
+		step.CommandId = uint64(nullableCommandId.Int64)
+		step.FailureMessage = nullableFailureMessage.String
+
+		record.Steps = append(record.Steps, step)
+	}
+
+	return record, rows.Err()
+}
+
+// Updates status, launched command and failure message of the pipeline's
+// step.
+func (connection *Connection) UpdatePipelineStep(pipelineId uint64, step PipelineStepTableRecord) error {
+	ctx, cancel := createTimeoutDefaultContext()
+	defer cancel()
+
+	commandId := sql.NullInt64{Int64: int64(step.CommandId), Valid: step.CommandId != 0}
+	failureMessage := sql.NullString{String: step.FailureMessage, Valid: step.FailureMessage != ""}
+
+	_, err := connection.db.ExecContext(
+		ctx,
+		`
+			UPDATE pipeline_steps SET status = $3, command_id = $4, failure_message = $5
+			WHERE pipeline_id = $1 AND name = $2
+		`,
+		pipelineId,
+		step.Name,
+		step.Status,
+		commandId,
+		failureMessage,
+	)
+
+	return err
+}
+
+// Sets final status of the pipeline.
+func (connection *Connection) FinishPipeline(pipelineId uint64, status string) error {
+	ctx, cancel := createTimeoutDefaultContext()
+	defer cancel()
+
+	_, err := connection.db.ExecContext(
+		ctx,
+		`UPDATE pipelines SET status = $2, finished_at = now() WHERE id = $1`,
+		pipelineId,
+		status,
+	)
+
+	return err
+}
+
+// Returns IDs of the pipelines with provided status that are executed by
+// the server instance.
+func (connection *Connection) GetInstancePipelines(instance string, status string) ([]uint64, error) {
+	ctx, cancel := createTimeoutDefaultContext()
+	defer cancel()
+
+	rows, err := connection.db.QueryContext(
+		ctx,
+		`SELECT id FROM pipelines WHERE instance = $1 AND status = $2 ORDER BY id`,
+		instance,
+		status,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []uint64
+	for rows.Next() {
+		var id uint64
+		if err := rows.Scan(&id); err != nil {
+			return ids, err
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
+
+// Requests cancellation of the pipeline from the server instance that
+// executes it. Returns false if pipeline doesn't have provided status.
+func (connection *Connection) RequestPipelineCancel(pipelineId uint64, status string) (bool, error) {
+	ctx, cancel := createTimeoutDefaultContext()
+	defer cancel()
+
+	result, err := connection.db.ExecContext(
+		ctx,
+		`UPDATE pipelines SET cancel_requested = TRUE WHERE id = $1 AND status = $2`,
+		pipelineId,
+		status,
+	)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	return affected == 1, err
+}
+
+// Checks whether cancellation of the pipeline is requested.
+func (connection *Connection) IsPipelineCancelRequested(pipelineId uint64) (bool, error) {
+	ctx, cancel := createTimeoutDefaultContext()
+	defer cancel()
+
+	var requested bool
+	err := connection.db.QueryRowContext(
+		ctx,
+		`SELECT cancel_requested FROM pipelines WHERE id = $1`,
+		pipelineId,
+	).Scan(&requested)
+
+	return requested, err
+}
+
+// Returns IDs of delayed commands whose launch time has come.
+func (connection *Connection) GetDueCommands(now time.Time) ([]uint64, error) {
+	ctx, cancel := createTimeoutDefaultContext()
+	defer cancel()
+
+	rows, err := connection.db.QueryContext(
+		ctx,
+		`
+			SELECT c.id
+			FROM commands AS c
+			JOIN statuses AS s ON c.id = s.id
+			WHERE s.status = $1 AND c.run_at <= $2
+			ORDER BY c.run_at
+		`,
+		StatusScheduled,
+		now,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []uint64
+	for rows.Next() {
+		var id uint64
+		if err := rows.Scan(&id); err != nil {
+			return ids, err
+		}
+
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
+
+// Moves delayed command into "created" status. Returns false if command
+// isn't delayed anymore, for example it was launched by another server
+// instance or cancelled.
+func (connection *Connection) ClaimScheduledCommand(recordId uint64) (bool, error) {
+	ctx, cancel := createTimeoutDefaultContext()
+	defer cancel()
+
+	result, err := connection.db.ExecContext(
+		ctx,
+		`UPDATE statuses SET status = $3 WHERE id = $1 AND status = $2`,
+		recordId,
+		StatusScheduled,
+		StatusCreated,
+	)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	return affected == 1, err
+}
+
+// Cancels delayed command before its launch. Its workspace is expired
+// immediately. Returns false if command isn't delayed.
+func (connection *Connection) CancelScheduledCommand(recordId uint64) (bool, error) {
+	ctx, cancel := createTimeoutDefaultContext()
+	defer cancel()
+
+	tx, err := connection.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+
+	result, err := tx.ExecContext(
+		ctx,
+		`UPDATE statuses SET status = $3, exit_code = -1, verdict = 'failed' WHERE id = $1 AND status = $2`,
+		recordId,
+		StatusScheduled,
+		StatusInterrupted,
+	)
+	if err != nil {
+		tx.Rollback()
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil || affected != 1 {
+		tx.Rollback()
+		return false, err
+	}
+
+	_, err = tx.ExecContext(
+		ctx,
+		`UPDATE workspaces SET expires_at = now() WHERE id = $1 AND removed_at IS NULL`,
+		recordId,
+	)
+	if err != nil {
+		tx.Rollback()
+		return false, err
+	}
+
+	statuses := StatusesTableRecord{Status: StatusInterrupted, ExitCode: -1, Verdict: "failed"}
+	if err := insertEvent(ctx, tx, recordId, EventFinished, statuses); err != nil {
+		tx.Rollback()
+		return false, err
+	}
+
+	return true, tx.Commit()
+}
+
+// Pushes schedule into the database. Returns ID of the schedule.
+func (connection *Connection) InsertSchedule(schedule ScheduleTableRecord) (uint64, error) {
+	ctx, cancel := createTimeoutDefaultContext()
+	defer cancel()
+
+	var scheduleId uint64
+	err := connection.db.QueryRowContext(
+		ctx,
+		`
+			INSERT INTO schedules (cron, timezone, overlap, spec, next_run_at)
+			VALUES ($1, $2, $3, $4, $5)
+			RETURNING id
+		`,
+		schedule.Cron,
+		schedule.Timezone,
+		schedule.Overlap,
+		[]byte(schedule.Spec),
+		schedule.NextRunAt,
+	).Scan(&scheduleId)
+
+	return scheduleId, err
+}
+
+// Returns all schedules.
+func (connection *Connection) GetSchedules() ([]ScheduleTableRecord, error) {
+	return connection.getSchedules(`ORDER BY id`)
+}
+
+// Returns enabled schedules that must be fired at provided time.
+func (connection *Connection) GetDueSchedules(now time.Time) ([]ScheduleTableRecord, error) {
+	return connection.getSchedules(`WHERE enabled AND next_run_at <= $1 ORDER BY next_run_at`, now)
+}
+
+// Returns schedule with provided ID.
+func (connection *Connection) GetSchedule(scheduleId uint64) (ScheduleTableRecord, error) {
+	schedules, err := connection.getSchedules(`WHERE id = $1`, scheduleId)
+	if err != nil {
+		return ScheduleTableRecord{}, err
+	}
+	if len(schedules) == 0 {
+		return ScheduleTableRecord{}, sql.ErrNoRows
+	}
+
+	return schedules[0], nil
+}
+
+func (connection *Connection) getSchedules(condition string, args ...any) ([]ScheduleTableRecord, error) {
+	ctx, cancel := createTimeoutDefaultContext()
+	defer cancel()
+
+	rows, err := connection.db.QueryContext(
+		ctx,
+		`
+			SELECT id, cron, timezone, overlap, spec, enabled, next_run_at, created_at
+			FROM schedules
+		`+condition,
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var records []ScheduleTableRecord
+	for rows.Next() {
+		var record ScheduleTableRecord
+		var spec []byte
+
+		err := rows.Scan(
+			&record.Id,
+			&record.Cron,
+			&record.Timezone,
+			&record.Overlap,
+			&spec,
+			&record.Enabled,
+			&record.NextRunAt,
+			&record.CreatedAt,
+		)
+		if err != nil {
+			return records, err
+		}
+		record.Spec = spec
+
+		records = append(records, record)
+	}
+
+	return records, rows.Err()
+}
+
+// Moves fire time of the schedule from current to next one. Returns false
+// if fire time was already moved, for example by another server instance.
+// Zero next time means that schedule won't be fired anymore.
+func (connection *Connection) ClaimScheduleRun(scheduleId uint64, current, next time.Time) (bool, error) {
+	ctx, cancel := createTimeoutDefaultContext()
+	defer cancel()
+
+	nextRunAt := sql.NullTime{Time: next, Valid: !next.IsZero()}
+
+	result, err := connection.db.ExecContext(
+		ctx,
+		`UPDATE schedules SET next_run_at = $3 WHERE id = $1 AND next_run_at = $2`,
+		scheduleId,
+		current,
+		nextRunAt,
+	)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	return affected == 1, err
+}
+
+// Disables the schedule, so it won't be fired anymore. Returns false if
+// there is no such schedule.
+func (connection *Connection) DisableSchedule(scheduleId uint64) (bool, error) {
+	ctx, cancel := createTimeoutDefaultContext()
+	defer cancel()
+
+	result, err := connection.db.ExecContext(
+		ctx,
+		`UPDATE schedules SET enabled = FALSE WHERE id = $1`,
+		scheduleId,
+	)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	return affected == 1, err
+}
+
+// Updates launched command's outputs and statuses.
+func (connection *Connection) UpdateRecord(
+	recordId uint64,
+	outputs *OutputsTableRecord,
+	statuses StatusesTableRecord,
+) error {
+	ctx, cancel := createTimeoutDefaultContext()
+	defer cancel()
+
+	tx, err := connection.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	outputKey, errorsKey := sql.NullString{}, sql.NullString{}
+	if outputs.OutputKey != "" {
+		outputKey.String = outputs.OutputKey
+		outputKey.Valid = true
+	}
+	if outputs.ErrorsKey != "" {
+		errorsKey.String = outputs.ErrorsKey
+		errorsKey.Valid = true
+	}
+
+	_, err = tx.ExecContext(
+		ctx,
+		`
+			UPDATE outputs SET
+				output = $2, errors = $3,
+				output_key = $4, output_size = $5,
+				errors_key = $6, errors_size = $7,
+				output_partial = $8, errors_partial = $9
+			WHERE id = $1
+		`,
+		recordId,
+		outputs.Output,
+		outputs.Errors,
+		outputKey,
+		outputs.OutputSize,
+		errorsKey,
+		outputs.ErrorsSize,
+		sql.NullString{String: outputs.OutputPartial, Valid: outputs.OutputPartial != ""},
+		sql.NullString{String: outputs.ErrorsPartial, Valid: outputs.ErrorsPartial != ""},
+	)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	exitCode := sql.NullInt32{}
+	if statuses.ExitCode != -2 {
+		exitCode.Int32 = int32(statuses.ExitCode)
+		exitCode.Valid = true
+	}
+
+	failureClass, failureMessage := sql.NullString{}, sql.NullString{}
+	if statuses.FailureClass != "" {
+		failureClass.String = statuses.FailureClass
+		failureClass.Valid = true
+		failureMessage.String = statuses.FailureMessage
+		failureMessage.Valid = true
+	}
+
+	verdict := sql.NullString{String: statuses.Verdict, Valid: statuses.Verdict != ""}
+	verdictMessage := sql.NullString{String: statuses.VerdictMessage, Valid: statuses.VerdictMessage != ""}
+
+	_, err = tx.ExecContext(
+		ctx,
+		`
+			UPDATE statuses SET
+				status = $2, exit_code = $3,
+				failure_class = $4, failure_message = $5,
+				verdict = $6, verdict_message = $7
+			WHERE id = $1
+		`,
+		recordId,
+		statuses.Status,
+		exitCode,
+		failureClass,
+		failureMessage,
+		verdict,
+		verdictMessage,
+	)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if IsTerminalStatus(statuses.Status) {
+		if err := insertEvent(ctx, tx, recordId, EventFinished, statuses); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// Struct that represents essential command info in the "commands" table.
+type CommandTableRecord struct {
+	Id uint64 `json:"id"`
+
+	Command string `json:"command"`
+
+	// Key that prevents same request from being launched twice and hash of
+	// the request it was provided with.
+	IdempotencyKey string `json:"idempotency_key,omitempty"`
+	RequestSha256  string `json:"-"`
+
+	// Batch that command was launched with.
+	BatchId uint64 `json:"batch_id,omitempty"`
+
+	// Named group that command belongs to.
+	Group string `json:"group,omitempty"`
+
+	// Schedule that launched the command.
+	ScheduleId uint64 `json:"schedule_id,omitempty"`
+
+	// Time when delayed command must be launched.
+	RunAt *time.Time `json:"run_at,omitempty"`
+
+	// Command launched with retry policy and number of this attempt.
+	ParentId uint64 `json:"parent_id,omitempty"`
+	Attempt  int    `json:"attempt,omitempty"`
+
+	// Command that this one is re-run of.
+	RerunOf uint64 `json:"rerun_of,omitempty"`
+
+	// Pipeline that launched the command as its step.
+	PipelineId uint64 `json:"pipeline_id,omitempty"`
+}
+
+// Struct that represents command's inputs in the "inputs" table.
+type InputTableRecord struct {
+	id uint64
+
+	Workdir string `json:"workdir,omitempty"`
+
+	Input string                      `json:"input"`
+	Env   []executor.EnvironmentEntry `json:"env"`
+
+	// Streamed inputs are too large or binary to be kept in the database,
+	// so only their sizes and hashes are always stored.
+	InputSize   int64  `json:"input_size"`
+	InputSha256 string `json:"input_sha256,omitempty"`
+
+	// Commands whose outputs were passed as input and as environment
+	// variables.
+	StdinFrom uint64            `json:"stdin_from,omitempty"`
+	EnvFrom   map[string]uint64 `json:"env_from,omitempty"`
+
+	// Time after which command is killed and policy of its retries.
+	TimeoutSeconds float64         `json:"timeout_seconds,omitempty"`
+	Retry          json.RawMessage `json:"retry,omitempty"`
+
+	// Success criteria of the command.
+	Success json.RawMessage `json:"success,omitempty"`
+
+	// Files that were written into command's working directory before
+	// its launch.
+	Files []InputFileTableRecord `json:"files,omitempty"`
+
+	// Glob patterns of files collected from working directory after
+	// command's finish.
+	Artifacts []string `json:"artifacts,omitempty"`
+
+	// Temporary working directory created for the command.
+	Workspace *WorkspaceTableRecord `json:"workspace,omitempty"`
+}
+
+// Struct that represents temporary working directory of the command in
+// the "workspaces" table.
+type WorkspaceTableRecord struct {
+	id uint64
+
+	Path     string `json:"path"`
+	Template string `json:"template,omitempty"`
+
+	// Cleanup policy and retention time of the workspace.
+	Cleanup     string `json:"cleanup"`
+	RetainHours int    `json:"retain_hours,omitempty"`
+
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	RemovedAt *time.Time `json:"removed_at,omitempty"`
+}
+
+// Returns ID of the command that owns the workspace.
+func (record WorkspaceTableRecord) Id() uint64 {
+	return record.id
+}
+
+// Struct that represents file attached to the command in the "input_files"
+// table.
+type InputFileTableRecord struct {
+	id uint64
+
+	Path   string `json:"path"`
+	Mode   uint32 `json:"mode"`
+	Size   int64  `json:"size"`
+	Sha256 string `json:"sha256"`
+}
+
+// Struct that represents command's outputs in the "outputs" table.
+type OutputsTableRecord struct {
+	id uint64
+
+	// Raw bytes of the streams. Valid UTF-8 is encoded into JSON as is,
+	// anything else - as base64 with appropriate "*_encoding" field.
+	Output []byte `json:"output"`
+	Errors []byte `json:"errors"`
+
+	// Outputs that are too large are moved into the storage. In that case
+	// only their keys and sizes are kept in the database.
+	OutputKey  string `json:"output_key,omitempty"`
+	OutputSize int64  `json:"output_size"`
+	ErrorsKey  string `json:"errors_key,omitempty"`
+	ErrorsSize int64  `json:"errors_size"`
+
+	// Names of the outputs that are moved into the storage, but aren't
+	// complete yet because command is running.
+	OutputPartial string `json:"-"`
+	ErrorsPartial string `json:"-"`
+
+	// Links to download stored outputs. Populated by the API.
+	OutputUrl string `json:"output_url,omitempty"`
+	ErrorsUrl string `json:"errors_url,omitempty"`
+}
+
+func (record OutputsTableRecord) MarshalJSON() ([]byte, error) {
+	type alias OutputsTableRecord
+
+	output, outputEncoding := encodeStream(record.Output)
+	errors, errorsEncoding := encodeStream(record.Errors)
+
+	return json.Marshal(struct {
+		alias
+
+		Output         string `json:"output"`
+		OutputEncoding string `json:"output_encoding"`
+		Errors         string `json:"errors"`
+		ErrorsEncoding string `json:"errors_encoding"`
+	}{
+		alias:          alias(record),
+		Output:         output,
+		OutputEncoding: outputEncoding,
+		Errors:         errors,
+		ErrorsEncoding: errorsEncoding,
+	})
+}
+
+// Struct that represents command's results in the "statuses" table.
+type StatusesTableRecord struct {
+	id uint64
+
+	Status   string `json:"status"`
+	ExitCode int    `json:"exit_code"`
+
+	// Reason why command wasn't started or failed. Class is one of the
+	// executor's error classes.
+	FailureClass   string `json:"failure_class,omitempty"`
+	FailureMessage string `json:"failure_message,omitempty"`
+
+	// Whether finished command meets its success criteria and why it
+	// doesn't.
+	Verdict        string `json:"verdict,omitempty"`
+	VerdictMessage string `json:"verdict_message,omitempty"`
+}
+
+// Struct that represents file collected from command's working directory
+// in the "artifacts" table.
+type ArtifactTableRecord struct {
+	id uint64
+
+	Name string `json:"name"`
+	Key  string `json:"key"`
+	Size int64  `json:"size"`
+
+	// Link to download artifact. Populated by the API.
+	Url string `json:"url,omitempty"`
+}
+
+// Struct that represents pipeline of dependent commands in the "pipelines"
+// table.
+type PipelineTableRecord struct {
+	Id     uint64 `json:"id"`
+	Status string `json:"status"`
+
+	CreatedAt  time.Time  `json:"created_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+
+	Steps []PipelineStepTableRecord `json:"steps"`
+}
+
+// Struct that represents entry of the command's history in the "events"
+// table.
+type EventTableRecord struct {
+	commandId uint64
+
+	Id   uint64 `json:"id"`
+	Type string `json:"type"`
+
+	// Details of the event, depend on its type.
+	Data json.RawMessage `json:"data,omitempty"`
+
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Struct that represents data written into the open stdin of the command
+// in the "stdin_chunks" table.
+type StdinChunkTableRecord struct {
+	commandId uint64
+
+	Data []byte `json:"data"`
+
+	// Address of the client that wrote the data.
+	RemoteAddr string `json:"remote_addr"`
+
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Struct that represents step of the pipeline in the "pipeline_steps"
+// table.
+type PipelineStepTableRecord struct {
+	pipelineId uint64
+
+	Name      string   `json:"name"`
+	DependsOn []string `json:"depends_on"`
+	Condition string   `json:"condition"`
+
+	// Launch request of the step as is.
+	Spec json.RawMessage `json:"spec"`
+
+	Status string `json:"status"`
+
+	// Command launched for the step and the reason why it couldn't be
+	// launched.
+	CommandId      uint64 `json:"command_id,omitempty"`
+	FailureMessage string `json:"failure_message,omitempty"`
+}
+
+// Struct that represents cron schedule of the command in the "schedules"
+// table.
+type ScheduleTableRecord struct {
+	Id uint64 `json:"id"`
+
+	Cron     string `json:"cron"`
+	Timezone string `json:"timezone"`
+
+	// What to do if previous run of the schedule isn't finished yet.
+	Overlap string `json:"overlap"`
+
+	// Launch request of the command as is.
+	Spec json.RawMessage `json:"spec"`
+
+	Enabled   bool       `json:"enabled"`
+	NextRunAt *time.Time `json:"next_run_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// Struct that stores command with its statuses.
+type CommandSummaryRecord struct {
+	Command  CommandTableRecord  `json:"command_info"`
+	Statuses StatusesTableRecord `json:"statuses"`
+}
+
+// Struct that stores full command info.
+type FullCommandRecord struct {
+	Command   CommandTableRecord    `json:"command_info"`
+	Input     InputTableRecord      `json:"input_info"`
+	Outputs   OutputsTableRecord    `json:"outputs"`
+	Statuses  StatusesTableRecord   `json:"statuses"`
+	Artifacts []ArtifactTableRecord `json:"artifacts,omitempty"`
+
+	// Attempts of the command launched with retry policy.
+	Attempts []CommandSummaryRecord `json:"attempts,omitempty"`
+
+	// Commands that were launched as re-runs of this one.
+	Reruns []CommandSummaryRecord `json:"reruns,omitempty"`
+}
+
+func checkDefaultCredentials(credentials *Credentials) {
+	if credentials.Username == "" {
+		credentials.Username = DefaultUsername
+	}
+	if credentials.Password == "" {
+		credentials.Password = DefaultPassword
+	}
+	if credentials.Host == "" {
+		credentials.Host = DefaultHost
+	}
+	if credentials.Port == 0 {
+		credentials.Port = DefaultPort
+	}
+}
+
+// Returns stream as a string and its encoding - "utf8" or "base64".
+func encodeStream(stream []byte) (string, string) {
+	if utf8.Valid(stream) {
+		return string(stream), EncodingUtf8
+	}
+
+	return base64.StdEncoding.EncodeToString(stream), EncodingBase64
+}
+
+// Returns value of the JSONB column: empty data is stored as NULL.
+func nullableJson(data []byte) any {
+	if len(data) == 0 {
+		return nil
+	}
+
+	return data
+}
+
+func createTimeoutDefaultContext() (context.Context, context.CancelFunc) {
+	return context.WithTimeoutCause(
+		context.Background(),
+		defaultTimeout,
+		fmt.Errorf("operation timed out"),
+	)
 }
@@ -3,10 +3,16 @@ package executor
 import (
 	"context"
 	"database/sql/driver"
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
+	"os"
 	"os/exec"
 	"strings"
+	"sync"
+	"syscall"
+	"time"
 )
 
 // Struct that represents environment variable.
@@ -44,11 +50,43 @@ type Executor struct {
 	Env     []EnvironmentEntry
 }
 
+// Classes of the errors that can happen to the command
+const (
+	// working directory doesn't exist or isn't accessible
+	ErrorClassWorkdir = "workdir_error"
+	// bash isn't found on the server
+	ErrorClassInterpreter = "interpreter_not_found"
+	// server isn't allowed to launch the command
+	ErrorClassPermission = "permission_denied"
+	// any other error that prevents command from being started
+	ErrorClassStart = "start_error"
+	// command is started but its streams failed
+	ErrorClassIO = "io_error"
+	// command is killed because its timeout is exceeded
+	ErrorClassTimeout = "timeout"
+	// server is restarted while command was running
+	ErrorClassRestart = "server_restart"
+)
+
+// Error that prevents command from being started.
+type StartError struct {
+	Class string
+	Err   error
+}
+
+func (err *StartError) Error() string {
+	return fmt.Sprintf("%s: %s", err.Class, err.Err)
+}
+
+func (err *StartError) Unwrap() error {
+	return err.Err
+}
+
 // Runs given command with provided input stream reader and writes its
 // output to outWriter and errors to errWriter.
 //
-// Returns error channel to determine when command is finished and
-// cancelation function to interrupt command.
+// Returns error channel to determine when command is finished. If command
+// isn't started - its *StartError is sent into the channel.
 func (executor *Executor) RunScript(
 	ctx context.Context,
 
@@ -58,21 +96,224 @@ func (executor *Executor) RunScript(
 
 	command string,
 ) <-chan error {
+	process, err := executor.Start(ctx, inReader, outWriter, errWriter, command)
+	if err != nil {
+		isDone := make(chan error, 1)
+		isDone <- err
+		return isDone
+	}
+
+	return process.Done
+}
+
+// Same as RunScript, but returns started process or *StartError right away
+// if command can't be started. Command is launched in its own process
+// group.
+func (executor *Executor) Start(
+	ctx context.Context,
+
+	inReader io.Reader,
+	outWriter io.Writer,
+	errWriter io.Writer,
+
+	command string,
+) (*Process, error) {
+	return executor.start(ctx, inReader, outWriter, errWriter, command, nil)
+}
+
+// Same as Start, but standard input of the command is a pipe that is kept
+// open after inReader is copied into it: more data can be written with
+// WriteStdin until CloseStdin is called or command is finished.
+func (executor *Executor) StartWithStdin(
+	ctx context.Context,
+
+	inReader io.Reader,
+	outWriter io.Writer,
+	errWriter io.Writer,
+
+	command string,
+) (*Process, error) {
+	pipeReader, pipeWriter, err := os.Pipe()
+	if err != nil {
+		return nil, &StartError{Class: ErrorClassStart, Err: err}
+	}
+
+	process, err := executor.start(ctx, pipeReader, outWriter, errWriter, command, pipeWriter)
+	// child has its own copy of the reading end
+	pipeReader.Close()
+	if err != nil {
+		pipeWriter.Close()
+		return nil, err
+	}
+
+	// initial input goes first
+	process.stdinLocker.Lock()
+	go func() {
+		defer process.stdinLocker.Unlock()
+
+		if inReader != nil {
+			io.Copy(pipeWriter, inReader)
+		}
+	}()
+
+	return process, nil
+}
+
+func (executor *Executor) start(
+	ctx context.Context,
+
+	inReader io.Reader,
+	outWriter io.Writer,
+	errWriter io.Writer,
+
+	command string,
+	stdin *os.File,
+) (*Process, error) {
 	cmd := exec.CommandContext(ctx, "bash", "-c", command)
 	cmd.Env = parseEnv(executor.Env)
 	cmd.Dir = executor.Workdir
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
 
 	cmd.Stdin = inReader
 	cmd.Stdout = outWriter
 	cmd.Stderr = errWriter
 
+	// child of the new process group reports failed chdir as failed exec,
+	// so working directory is checked beforehand
+	if err := checkWorkdir(executor.Workdir); err != nil {
+		return nil, &StartError{Class: ErrorClassWorkdir, Err: err}
+	}
+
+	if err := cmd.Start(); err != nil {
+		return nil, &StartError{Class: classifyStartError(err), Err: err}
+	}
+
 	isDone := make(chan error)
+	process := &Process{Done: isDone, cmd: cmd, stdin: stdin}
 	go func() {
-		err := cmd.Run()
+		err := cmd.Wait()
+		process.CloseStdin()
 		isDone <- err
 	}()
 
-	return isDone
+	return process, nil
+}
+
+// Error that is returned when standard input of the command isn't open.
+var ErrStdinClosed = errors.New("stdin of the command isn't open")
+
+// Started command.
+type Process struct {
+	// Receives result of the command once it's finished.
+	Done <-chan error
+
+	cmd *exec.Cmd
+
+	// writing end of the open standard input
+	stdin       *os.File
+	stdinLocker sync.Mutex
+}
+
+// Writes data into the open standard input of the command. Fails if
+// command doesn't read it for the timeout.
+func (process *Process) WriteStdin(data []byte, timeout time.Duration) (int, error) {
+	process.stdinLocker.Lock()
+	defer process.stdinLocker.Unlock()
+
+	if process.stdin == nil {
+		return 0, ErrStdinClosed
+	}
+
+	process.stdin.SetWriteDeadline(time.Now().Add(timeout))
+	return process.stdin.Write(data)
+}
+
+// Closes standard input of the command, so it gets end of file.
+func (process *Process) CloseStdin() error {
+	process.stdinLocker.Lock()
+	defer process.stdinLocker.Unlock()
+
+	if process.stdin == nil {
+		return ErrStdinClosed
+	}
+
+	err := process.stdin.Close()
+	process.stdin = nil
+	return err
+}
+
+// Returns PID of the command's shell, that is also ID of its process group.
+func (process *Process) Pid() int {
+	return process.cmd.Process.Pid
+}
+
+// Sends signal to the command's shell or to its whole process group.
+func (process *Process) Signal(signal syscall.Signal, group bool) error {
+	if group {
+		return syscall.Kill(-process.cmd.Process.Pid, signal)
+	}
+
+	return process.cmd.Process.Signal(signal)
+}
+
+// Signals that can be sent to the running command by their names.
+var signals = map[string]syscall.Signal{
+	"HUP":   syscall.SIGHUP,
+	"INT":   syscall.SIGINT,
+	"QUIT":  syscall.SIGQUIT,
+	"KILL":  syscall.SIGKILL,
+	"USR1":  syscall.SIGUSR1,
+	"USR2":  syscall.SIGUSR2,
+	"ALRM":  syscall.SIGALRM,
+	"TERM":  syscall.SIGTERM,
+	"WINCH": syscall.SIGWINCH,
+}
+
+// Returns signal by its name with or without "SIG" prefix, for example
+// "HUP" or "SIGTERM".
+func ParseSignal(name string) (syscall.Signal, error) {
+	signal, exists := signals[strings.TrimPrefix(strings.ToUpper(name), "SIG")]
+	if !exists {
+		return 0, fmt.Errorf("unsupported signal \"%s\"", name)
+	}
+
+	return signal, nil
+}
+
+func checkWorkdir(workdir string) error {
+	if workdir == "" {
+		return nil
+	}
+
+	info, err := os.Stat(workdir)
+	if err != nil {
+		var pathErr *fs.PathError
+		if errors.As(err, &pathErr) {
+			err = pathErr.Err
+		}
+		return &fs.PathError{Op: "chdir", Path: workdir, Err: err}
+	}
+	if !info.IsDir() {
+		return &fs.PathError{Op: "chdir", Path: workdir, Err: syscall.ENOTDIR}
+	}
+
+	return nil
+}
+
+func classifyStartError(err error) string {
+	var pathErr *fs.PathError
+	if errors.As(err, &pathErr) && pathErr.Op == "chdir" {
+		return ErrorClassWorkdir
+	}
+
+	switch {
+	case errors.Is(err, exec.ErrNotFound):
+		return ErrorClassInterpreter
+	case errors.Is(err, fs.ErrPermission):
+		return ErrorClassPermission
+	default:
+		return ErrorClassStart
+	}
 }
 
 func parseEnv(entries []EnvironmentEntry) []string {
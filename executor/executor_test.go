@@ -3,8 +3,10 @@ package executor
 import (
 	"bytes"
 	"context"
+	"errors"
 	"os/exec"
 	"strings"
+	"syscall"
 	"testing"
 	"time"
 )
@@ -140,3 +142,140 @@ func TestRunScriptInput(t *testing.T) {
 		t.Fatalf("output must be \"amogus\", got \"%s\"", out.String())
 	}
 }
+
+func TestStartMissingWorkdir(t *testing.T) {
+	executor := Executor{Workdir: "/amogus/sus"}
+
+	process, err := executor.Start(context.Background(), nil, nil, nil, "pwd")
+	if process != nil {
+		t.Fatalf("process must be nil if command isn't started")
+	}
+
+	var startErr *StartError
+	if !errors.As(err, &startErr) {
+		t.Fatalf("runner had to return StartError, but got %T", err)
+	}
+	if startErr.Class != ErrorClassWorkdir {
+		t.Fatalf("error class must be \"%s\", got \"%s\"", ErrorClassWorkdir, startErr.Class)
+	}
+}
+
+func TestRunScriptMissingWorkdir(t *testing.T) {
+	executor := Executor{Workdir: "/amogus/sus"}
+
+	isDone := executor.RunScript(context.Background(), nil, nil, nil, "pwd")
+	err := <-isDone
+
+	var startErr *StartError
+	if !errors.As(err, &startErr) {
+		t.Fatalf("runner had to return StartError, but got %T", err)
+	}
+}
+
+func TestProcessSignal(t *testing.T) {
+	executor := Executor{}
+
+	process, err := executor.Start(context.Background(), nil, nil, nil, "sleep 10")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := process.Signal(syscall.SIGTERM, false); err != nil {
+		t.Fatal(err)
+	}
+
+	assertSignaled(t, <-process.Done, syscall.SIGTERM)
+}
+
+func TestProcessSignalGroup(t *testing.T) {
+	executor := Executor{}
+
+	process, err := executor.Start(context.Background(), nil, nil, nil, "sleep 10 & wait")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := process.Signal(syscall.SIGUSR1, true); err != nil {
+		t.Fatal(err)
+	}
+
+	assertSignaled(t, <-process.Done, syscall.SIGUSR1)
+}
+
+func TestStartWithStdin(t *testing.T) {
+	executor := Executor{}
+
+	out := bytes.Buffer{}
+
+	process, err := executor.StartWithStdin(context.Background(), strings.NewReader("amo"), &out, nil, "cat -")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := process.WriteStdin([]byte("gus"), time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if err := process.CloseStdin(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := process.WriteStdin([]byte("sus"), time.Second); !errors.Is(err, ErrStdinClosed) {
+		t.Fatalf("writing into closed stdin must fail with ErrStdinClosed, got %v", err)
+	}
+
+	if err := <-process.Done; err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != "amogus" {
+		t.Fatalf("output must be \"amogus\", got \"%s\"", out.String())
+	}
+}
+
+func TestStartWithoutStdin(t *testing.T) {
+	executor := Executor{}
+
+	process, err := executor.Start(context.Background(), nil, nil, nil, "true")
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-process.Done
+
+	if _, err := process.WriteStdin([]byte("sus"), time.Second); !errors.Is(err, ErrStdinClosed) {
+		t.Fatalf("writing into stdin that isn't open must fail with ErrStdinClosed, got %v", err)
+	}
+}
+
+func TestParseSignal(t *testing.T) {
+	for name, expected := range map[string]syscall.Signal{
+		"HUP":     syscall.SIGHUP,
+		"sigusr1": syscall.SIGUSR1,
+		"SIGINT":  syscall.SIGINT,
+	} {
+		signal, err := ParseSignal(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if signal != expected {
+			t.Fatalf("signal of \"%s\" must be %s, got %s", name, expected, signal)
+		}
+	}
+
+	for _, name := range []string{"", "STOP", "amogus"} {
+		if _, err := ParseSignal(name); err == nil {
+			t.Fatalf("signal \"%s\" must be rejected", name)
+		}
+	}
+}
+
+func assertSignaled(t *testing.T, err error, signal syscall.Signal) {
+	t.Helper()
+
+	exitErr, ok := err.(*exec.ExitError)
+	if !ok {
+		t.Fatalf("runner had to return ExitError, but got %T", err)
+	}
+
+	status := exitErr.Sys().(syscall.WaitStatus)
+	if !status.Signaled() || status.Signal() != signal {
+		t.Fatalf("command must be killed by %s, got %s", signal, exitErr)
+	}
+}
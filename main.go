@@ -8,11 +8,21 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"storage"
 	"strconv"
+	"strings"
+	"time"
+	"workspace"
 )
 
 func main() {
 	port := flag.Uint("port", 8888, "Port where server will be launched")
+	storageDir := flag.String("storage", "artifacts", "Directory where large outputs are stored")
+	workspacesDir := flag.String("workspaces", "workspaces", "Directory where working directories of the commands are created")
+	allowedWorkdirs := flag.String("allowed-workdirs", "", "Comma-separated list of directories where commands are allowed to be launched, \"/\" allows any of them. If empty, \"workdir\" parameter is rejected")
+	templatesDir := flag.String("templates", "", "Directory with templates of the commands' workspaces")
+	outputThreshold := flag.Int("output-threshold", 1<<20, "Size in bytes after which output is moved into the storage")
+	instance := flag.String("instance", getHostname(), "Name of this server instance. Commands left running by the previous process of the instance are interrupted at startup")
 	flag.Parse()
 
 	log.SetFlags(log.Lshortfile)
@@ -24,14 +34,27 @@ func main() {
 	log.Println("connected to database")
 	defer conn.Close()
 
+	store, err := storage.NewLocalStore(*storageDir)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	workspaces, err := workspace.NewManager(*workspacesDir, *templatesDir, splitList(*allowedWorkdirs))
+	if err != nil {
+		log.Fatalln(err)
+	}
+
 	cancelHandler, err := api.NewCancelHandler(conn)
 	if err != nil {
 		log.Fatalln(err)
 	}
-	executeHandler, err := api.NewExecuteHandler(conn, cancelHandler)
+	executeHandler, err := api.NewExecuteHandler(conn, cancelHandler, store, *outputThreshold, workspaces, *instance)
 	if err != nil {
 		log.Fatalln(err)
 	}
+	if err := executeHandler.Recover(); err != nil {
+		log.Fatalln(err)
+	}
 	getCommandsHandler, err := api.NewGetCommandsHandler(conn)
 	if err != nil {
 		log.Fatalln(err)
@@ -40,11 +63,141 @@ func main() {
 	if err != nil {
 		log.Fatalln(err)
 	}
+	storageHandler, err := api.NewStorageHandler(store)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	outputHandler, err := api.NewOutputHandler(conn, store)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	rawOutputHandler, err := api.NewRawOutputHandler(conn, store)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	artifactHandler, err := api.NewArtifactHandler(conn, store)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	waitHandler, err := api.NewWaitHandler(conn, cancelHandler)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	batchHandler, err := api.NewBatchHandler(conn, executeHandler)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	getBatchHandler, err := api.NewGetBatchHandler(conn)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	cancelBatchHandler, err := api.NewCancelBatchHandler(conn, cancelHandler)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	getGroupHandler, err := api.NewGetGroupHandler(conn)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	cancelGroupHandler, err := api.NewCancelGroupHandler(conn, cancelHandler)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	pipelineHandler, err := api.NewPipelineHandler(conn, executeHandler)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	if err := pipelineHandler.Recover(); err != nil {
+		log.Fatalln(err)
+	}
+	getPipelineHandler, err := api.NewGetPipelineHandler(conn)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	cancelPipelineHandler, err := api.NewCancelPipelineHandler(pipelineHandler)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	scheduleHandler, err := api.NewScheduleHandler(conn, executeHandler)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	getSchedulesHandler, err := api.NewGetSchedulesHandler(conn)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	getScheduleHandler, err := api.NewGetScheduleHandler(conn)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	deleteScheduleHandler, err := api.NewDeleteScheduleHandler(conn)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	rerunHandler, err := api.NewRerunHandler(conn, executeHandler)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	signalHandler, err := api.NewSignalHandler(conn, cancelHandler)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	pauseHandler, err := api.NewPauseHandler(conn, cancelHandler)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	resumeHandler, err := api.NewResumeHandler(conn, cancelHandler)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	stdinHandler, err := api.NewStdinHandler(conn, cancelHandler)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	eventsHandler, err := api.NewEventsHandler(conn)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	workspaceJanitor, err := api.NewWorkspaceJanitor(conn, workspaces)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	go workspaceJanitor.Run(time.Minute)
+
+	scheduler, err := api.NewScheduler(conn, executeHandler)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	go scheduler.Run(time.Second)
 
 	http.Handle("GET /api/commands", getCommandsHandler)
 	http.Handle("GET /api/get_command", getFullCommandHandler)
+	http.Handle("GET /api/commands/{id}/output", outputHandler)
+	http.Handle("GET /api/commands/{id}/output/raw", rawOutputHandler)
+	http.Handle("GET /api/commands/{id}/artifacts/{name...}", artifactHandler)
+	http.Handle("GET /api/commands/{id}/wait", waitHandler)
 	http.Handle("POST /api/launch", executeHandler)
 	http.Handle("POST /api/cancel", cancelHandler)
+	http.Handle("POST /api/launch/batch", batchHandler)
+	http.Handle("GET /api/batches/{id}", getBatchHandler)
+	http.Handle("POST /api/batches/{id}/cancel", cancelBatchHandler)
+	http.Handle("GET /api/groups/{name}", getGroupHandler)
+	http.Handle("POST /api/groups/{name}/cancel", cancelGroupHandler)
+	http.Handle("POST /api/pipelines", pipelineHandler)
+	http.Handle("GET /api/pipelines/{id}", getPipelineHandler)
+	http.Handle("POST /api/pipelines/{id}/cancel", cancelPipelineHandler)
+	http.Handle("POST /api/schedules", scheduleHandler)
+	http.Handle("GET /api/schedules", getSchedulesHandler)
+	http.Handle("GET /api/schedules/{id}", getScheduleHandler)
+	http.Handle("DELETE /api/schedules/{id}", deleteScheduleHandler)
+	http.Handle("POST /api/commands/{id}/rerun", rerunHandler)
+	http.Handle("POST /api/commands/{id}/signal", signalHandler)
+	http.Handle("POST /api/commands/{id}/pause", pauseHandler)
+	http.Handle("POST /api/commands/{id}/resume", resumeHandler)
+	http.Handle("POST /api/commands/{id}/stdin", stdinHandler)
+	http.Handle("GET /api/commands/{id}/events", eventsHandler)
+	http.Handle("GET /api/storage/{key}", storageHandler)
 
 	http.ListenAndServe(fmt.Sprintf(":%d", *port), nil)
 }
@@ -71,3 +224,21 @@ func getCredentials() db.Credentials {
 
 	return credentials
 }
+
+func getHostname() string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		log.Println(err)
+		return "localhost"
+	}
+
+	return hostname
+}
+
+func splitList(list string) []string {
+	if list == "" {
+		return nil
+	}
+
+	return strings.Split(list, ",")
+}
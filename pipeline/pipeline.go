@@ -0,0 +1,172 @@
+package pipeline
+
+import (
+	"fmt"
+)
+
+// Conditions of the step's launch
+const (
+	// All dependencies are succeeded. Default condition
+	ConditionOnSuccess = "on_success"
+	// All dependencies are done and at least one of them is failed
+	ConditionOnFailure = "on_failure"
+	// All dependencies are done regardless of their result
+	ConditionAlways = "always"
+)
+
+// Statuses of the steps and of the whole pipeline
+const (
+	StatusPending   = "pending"
+	StatusRunning   = "running"
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+	StatusSkipped   = "skipped"
+	StatusCancelled = "cancelled"
+)
+
+// Struct that describes step of the pipeline and its dependencies.
+type Step struct {
+	Name      string
+	DependsOn []string
+	Condition string
+}
+
+// Returns true if status is final and won't be changed anymore.
+func IsDone(status string) bool {
+	switch status {
+	case StatusSucceeded, StatusFailed, StatusSkipped, StatusCancelled:
+		return true
+	default:
+		return false
+	}
+}
+
+// Checks that step names are unique, dependencies exist and don't form
+// cycles. Empty condition is treated as ConditionOnSuccess.
+func Validate(steps []Step) error {
+	if len(steps) == 0 {
+		return fmt.Errorf("pipeline must contain at least one step")
+	}
+
+	dependencies := make(map[string][]string, len(steps))
+	for _, step := range steps {
+		if step.Name == "" {
+			return fmt.Errorf("step name must be not empty")
+		}
+		if _, exists := dependencies[step.Name]; exists {
+			return fmt.Errorf("step \"%s\" is defined twice", step.Name)
+		}
+
+		switch step.Condition {
+		case "", ConditionOnSuccess, ConditionOnFailure, ConditionAlways:
+		default:
+			return fmt.Errorf("step \"%s\" has unknown condition \"%s\"", step.Name, step.Condition)
+		}
+
+		dependencies[step.Name] = step.DependsOn
+	}
+
+	for _, step := range steps {
+		for _, dependency := range step.DependsOn {
+			if _, exists := dependencies[dependency]; !exists {
+				return fmt.Errorf("step \"%s\" depends on unknown step \"%s\"", step.Name, dependency)
+			}
+		}
+	}
+
+	// depth-first search that marks steps on the current path as visiting
+	const (
+		visiting = 1
+		visited  = 2
+	)
+	marks := make(map[string]int, len(steps))
+
+	var visit func(name string) error
+	visit = func(name string) error {
+		switch marks[name] {
+		case visiting:
+			return fmt.Errorf("step \"%s\" depends on itself", name)
+		case visited:
+			return nil
+		}
+
+		marks[name] = visiting
+		for _, dependency := range dependencies[name] {
+			if err := visit(dependency); err != nil {
+				return err
+			}
+		}
+		marks[name] = visited
+
+		return nil
+	}
+
+	for _, step := range steps {
+		if err := visit(step.Name); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Returns the next status of the pending step according to statuses of
+// all steps: StatusRunning if it must be launched, StatusSkipped if its
+// condition can't be met anymore or StatusPending if some of its
+// dependencies are not done yet.
+func Next(step Step, statuses map[string]string) string {
+	succeeded, failed := 0, 0
+	for _, dependency := range step.DependsOn {
+		switch statuses[dependency] {
+		case StatusSucceeded:
+			succeeded++
+		case StatusFailed:
+			failed++
+		case StatusSkipped, StatusCancelled:
+		default:
+			return StatusPending
+		}
+	}
+
+	switch step.Condition {
+	case ConditionAlways:
+		return StatusRunning
+	case ConditionOnFailure:
+		if failed != 0 {
+			return StatusRunning
+		}
+	default:
+		if succeeded == len(step.DependsOn) {
+			return StatusRunning
+		}
+	}
+
+	return StatusSkipped
+}
+
+// Returns status of the whole pipeline: StatusRunning while some steps
+// are not done, StatusCancelled if any step is cancelled, StatusFailed if
+// any step is failed and StatusSucceeded otherwise.
+func Status(statuses map[string]string) string {
+	cancelled, failed := false, false
+	for _, status := range statuses {
+		switch status {
+		case StatusCancelled:
+			cancelled = true
+		case StatusFailed:
+			failed = true
+		case StatusSucceeded, StatusSkipped:
+		default:
+			return StatusRunning
+		}
+	}
+
+	switch {
+	case cancelled:
+		return StatusCancelled
+	case failed:
+		return StatusFailed
+	default:
+		return StatusSucceeded
+	}
+}
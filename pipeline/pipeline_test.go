@@ -0,0 +1,84 @@
+package pipeline
+
+import (
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	valid := []Step{
+		{Name: "build"},
+		{Name: "test", DependsOn: []string{"build"}},
+		{Name: "publish", DependsOn: []string{"build", "test"}},
+		{Name: "notify", DependsOn: []string{"publish"}, Condition: ConditionOnFailure},
+	}
+	if err := Validate(valid); err != nil {
+		t.Fatalf("validate had to return nil, but returned \"%s\"", err)
+	}
+
+	invalid := map[string][]Step{
+		"empty":     {},
+		"no name":   {{Name: ""}},
+		"duplicate": {{Name: "build"}, {Name: "build"}},
+		"unknown":   {{Name: "test", DependsOn: []string{"build"}}},
+		"condition": {{Name: "build", Condition: "sometimes"}},
+		"self":      {{Name: "build", DependsOn: []string{"build"}}},
+		"cycle": {
+			{Name: "build", DependsOn: []string{"publish"}},
+			{Name: "test", DependsOn: []string{"build"}},
+			{Name: "publish", DependsOn: []string{"test"}},
+		},
+	}
+	for name, steps := range invalid {
+		if err := Validate(steps); err == nil {
+			t.Fatalf("pipeline \"%s\" must be rejected", name)
+		}
+	}
+}
+
+func TestNext(t *testing.T) {
+	statuses := map[string]string{
+		"build":   StatusSucceeded,
+		"test":    StatusFailed,
+		"lint":    StatusRunning,
+		"publish": StatusSkipped,
+	}
+
+	cases := []struct {
+		step     Step
+		expected string
+	}{
+		{Step{Name: "first"}, StatusRunning},
+		{Step{Name: "a", DependsOn: []string{"build"}}, StatusRunning},
+		{Step{Name: "b", DependsOn: []string{"build", "test"}}, StatusSkipped},
+		{Step{Name: "c", DependsOn: []string{"build", "lint"}}, StatusPending},
+		{Step{Name: "d", DependsOn: []string{"publish"}}, StatusSkipped},
+		{Step{Name: "e", DependsOn: []string{"build"}, Condition: ConditionOnFailure}, StatusSkipped},
+		{Step{Name: "f", DependsOn: []string{"build", "test"}, Condition: ConditionOnFailure}, StatusRunning},
+		{Step{Name: "g", DependsOn: []string{"test", "publish"}, Condition: ConditionAlways}, StatusRunning},
+		{Step{Name: "h", DependsOn: []string{"lint"}, Condition: ConditionAlways}, StatusPending},
+	}
+
+	for _, c := range cases {
+		if status := Next(c.step, statuses); status != c.expected {
+			t.Fatalf("step \"%s\" must be %s, got %s", c.step.Name, c.expected, status)
+		}
+	}
+}
+
+func TestStatus(t *testing.T) {
+	cases := []struct {
+		statuses map[string]string
+		expected string
+	}{
+		{map[string]string{"a": StatusSucceeded, "b": StatusSkipped}, StatusSucceeded},
+		{map[string]string{"a": StatusSucceeded, "b": StatusPending}, StatusRunning},
+		{map[string]string{"a": StatusFailed, "b": StatusSucceeded}, StatusFailed},
+		{map[string]string{"a": StatusFailed, "b": StatusCancelled}, StatusCancelled},
+	}
+
+	for _, c := range cases {
+		if status := Status(c.statuses); status != c.expected {
+			t.Fatalf("pipeline %v must be %s, got %s", c.statuses, c.expected, status)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package retry
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"time"
+)
+
+// Maximal amount of attempts of the command
+const MaxAttempts = 20
+
+// Default values of the policy
+const (
+	DefaultBackoffSeconds    = 1
+	DefaultBackoffMultiplier = 2
+	DefaultMaxBackoffSeconds = 300
+	DefaultJitter            = 0.1
+)
+
+// Policy of retrying failed commands. Delay before every next attempt is
+// multiplied by BackoffMultiplier up to MaxBackoffSeconds and randomly
+// changed by Jitter fraction of it.
+type Policy struct {
+	// Amount of attempts including the first one.
+	MaxAttempts int `json:"max_attempts"`
+
+	BackoffSeconds    float64  `json:"backoff_seconds"`
+	BackoffMultiplier float64  `json:"backoff_multiplier"`
+	MaxBackoffSeconds float64  `json:"max_backoff_seconds"`
+	Jitter            *float64 `json:"jitter"`
+
+	// Exit codes of the failed commands that are retried. If empty - any
+	// failed command is retried.
+	ExitCodes []int `json:"exit_codes"`
+
+	// Whether commands killed by timeout are retried.
+	OnTimeout bool `json:"on_timeout"`
+}
+
+// Checks the policy and fills missing values with defaults.
+func (policy *Policy) Validate() error {
+	if policy.MaxAttempts < 1 || policy.MaxAttempts > MaxAttempts {
+		return fmt.Errorf("\"max_attempts\" must be in range 1-%d", MaxAttempts)
+	}
+
+	if policy.BackoffSeconds == 0 {
+		policy.BackoffSeconds = DefaultBackoffSeconds
+	}
+	if policy.BackoffMultiplier == 0 {
+		policy.BackoffMultiplier = DefaultBackoffMultiplier
+	}
+	if policy.MaxBackoffSeconds == 0 {
+		policy.MaxBackoffSeconds = DefaultMaxBackoffSeconds
+	}
+	if policy.Jitter == nil {
+		jitter := DefaultJitter
+		policy.Jitter = &jitter
+	}
+
+	switch {
+	case policy.BackoffSeconds < 0:
+		return fmt.Errorf("\"backoff_seconds\" must be non-negative")
+	case policy.BackoffMultiplier < 1:
+		return fmt.Errorf("\"backoff_multiplier\" must be at least 1")
+	case policy.MaxBackoffSeconds < 0:
+		return fmt.Errorf("\"max_backoff_seconds\" must be non-negative")
+	case *policy.Jitter < 0 || *policy.Jitter > 1:
+		return fmt.Errorf("\"jitter\" must be in range 0-1")
+	}
+
+	for _, exitCode := range policy.ExitCodes {
+		if exitCode <= 0 || exitCode > 255 {
+			return fmt.Errorf("exit code %d can't be retried", exitCode)
+		}
+	}
+
+	return nil
+}
+
+// Returns true if the attempt with such result must be followed by the
+// next one. Succeeded attempts are never retried, negative exit codes
+// mean that command was interrupted or wasn't started.
+func (policy *Policy) ShouldRetry(attempt, exitCode int, succeeded, timedOut bool) bool {
+	if attempt >= policy.MaxAttempts || succeeded {
+		return false
+	}
+	if timedOut {
+		return policy.OnTimeout
+	}
+	if exitCode < 0 {
+		return false
+	}
+
+	return len(policy.ExitCodes) == 0 || slices.Contains(policy.ExitCodes, exitCode)
+}
+
+// Returns delay after the attempt. Random must be in range [0, 1).
+func (policy *Policy) Delay(attempt int, random float64) time.Duration {
+	seconds := policy.BackoffSeconds * math.Pow(policy.BackoffMultiplier, float64(attempt-1))
+	seconds = min(seconds, policy.MaxBackoffSeconds)
+
+	if policy.Jitter != nil {
+		seconds *= 1 + *policy.Jitter*(2*random-1)
+	}
+
+	return time.Duration(seconds * float64(time.Second))
+}
@@ -0,0 +1,90 @@
+package retry
+
+import (
+	"testing"
+	"time"
+)
+
+func TestValidate(t *testing.T) {
+	policy := Policy{MaxAttempts: 3}
+	if err := policy.Validate(); err != nil {
+		t.Fatalf("validate had to return nil, but returned \"%s\"", err)
+	}
+	if policy.BackoffSeconds != DefaultBackoffSeconds || *policy.Jitter != DefaultJitter {
+		t.Fatalf("defaults must be filled, got %+v", policy)
+	}
+
+	jitter := 2.0
+	invalid := []Policy{
+		{MaxAttempts: 0},
+		{MaxAttempts: MaxAttempts + 1},
+		{MaxAttempts: 2, BackoffSeconds: -1},
+		{MaxAttempts: 2, BackoffMultiplier: 0.5},
+		{MaxAttempts: 2, Jitter: &jitter},
+		{MaxAttempts: 2, ExitCodes: []int{0}},
+	}
+	for _, policy := range invalid {
+		if err := policy.Validate(); err == nil {
+			t.Fatalf("policy %+v must be rejected", policy)
+		}
+	}
+}
+
+func TestShouldRetry(t *testing.T) {
+	policy := Policy{MaxAttempts: 3, ExitCodes: []int{1, 75}}
+
+	cases := []struct {
+		attempt   int
+		exitCode  int
+		succeeded bool
+		timedOut  bool
+		expected  bool
+	}{
+		{1, 1, false, false, true},
+		{2, 75, false, false, true},
+		{3, 1, false, false, false},
+		{1, 2, false, false, false},
+		{1, 0, false, false, false},
+		{1, 1, true, false, false},
+		{1, -1, false, false, false},
+		{1, -1, false, true, false},
+	}
+
+	for _, c := range cases {
+		if retry := policy.ShouldRetry(c.attempt, c.exitCode, c.succeeded, c.timedOut); retry != c.expected {
+			t.Fatalf("retry of attempt %d with exit code %d must be %v", c.attempt, c.exitCode, c.expected)
+		}
+	}
+
+	policy = Policy{MaxAttempts: 2, OnTimeout: true}
+	if !policy.ShouldRetry(1, -1, false, true) {
+		t.Fatalf("timed out attempt must be retried")
+	}
+	if !policy.ShouldRetry(1, 42, false, false) {
+		t.Fatalf("any failed attempt must be retried")
+	}
+	if !policy.ShouldRetry(1, 0, false, false) {
+		t.Fatalf("failed attempt with exit code 0 must be retried")
+	}
+	if policy.ShouldRetry(1, 42, true, false) {
+		t.Fatalf("succeeded attempt must not be retried")
+	}
+}
+
+func TestDelay(t *testing.T) {
+	policy := Policy{MaxAttempts: 10, BackoffSeconds: 2, MaxBackoffSeconds: 10}
+	if err := policy.Validate(); err != nil {
+		t.Fatalf("validate had to return nil, but returned \"%s\"", err)
+	}
+
+	expected := []time.Duration{2 * time.Second, 4 * time.Second, 8 * time.Second, 10 * time.Second}
+	for i, delay := range expected {
+		if actual := policy.Delay(i+1, 0.5); actual != delay {
+			t.Fatalf("delay after attempt %d must be %s, got %s", i+1, delay, actual)
+		}
+	}
+
+	if delay := policy.Delay(1, 0); delay != 1800*time.Millisecond {
+		t.Fatalf("jittered delay must be 1.8s, got %s", delay)
+	}
+}
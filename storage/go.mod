@@ -0,0 +1,3 @@
+module storage
+
+go 1.22.2
@@ -0,0 +1,351 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Struct that describes content stored in the Store.
+//
+// Key is a hex-encoded SHA-256 of the content, so equal contents share
+// the same object.
+type Object struct {
+	Key  string `json:"key"`
+	Size int64  `json:"size"`
+}
+
+// Interface of the place where large command outputs are kept instead of
+// the database.
+type Store interface {
+	// Creates new object writer. Written content becomes available
+	// only after successful Commit.
+	Create() (Writer, error)
+
+	// Opens stored object with provided key for reading.
+	Open(key string) (Reader, error)
+
+	// Opens object that is still being written by its partial name. Fails
+	// with fs.ErrNotExist after the object is released or aborted.
+	OpenPartial(name string) (Reader, error)
+}
+
+// Reader of the stored object.
+type Reader interface {
+	io.ReadSeekCloser
+	io.ReaderAt
+}
+
+// Writer of a single object into the Store.
+type Writer interface {
+	io.Writer
+
+	// Finishes writing and makes content available by its key. Content
+	// stays readable by the partial name until Release.
+	Commit() (Object, error)
+
+	// Removes partial name of the committed object.
+	Release() error
+
+	// Drops everything that was written.
+	Abort() error
+
+	// Returns name of the object until it's committed. Content that is
+	// already written can be read with Store.OpenPartial.
+	Partial() string
+}
+
+// Store that keeps objects in the content-addressed directory on the
+// local disk.
+//
+// Object with key "abcdef..." is placed into "<root>/ab/abcdef...".
+type LocalStore struct {
+	root string
+}
+
+// Creates new LocalStore in provided directory. Directory will be
+// created if it doesn't exist.
+func NewLocalStore(root string) (*LocalStore, error) {
+	if root == "" {
+		return nil, fmt.Errorf("storage root can't be empty")
+	}
+
+	if err := os.MkdirAll(filepath.Join(root, "tmp"), 0o755); err != nil {
+		return nil, err
+	}
+
+	store := new(LocalStore)
+	store.root = root
+	return store, nil
+}
+
+func (store *LocalStore) Create() (Writer, error) {
+	file, err := os.CreateTemp(filepath.Join(store.root, "tmp"), partialPrefix+"*")
+	if err != nil {
+		return nil, err
+	}
+
+	writer := new(localWriter)
+	writer.store = store
+	writer.file = file
+	writer.hash = sha256.New()
+	return writer, nil
+}
+
+func (store *LocalStore) Open(key string) (Reader, error) {
+	if !IsValidKey(key) {
+		return nil, fmt.Errorf("invalid object key")
+	}
+
+	return os.Open(store.objectPath(key))
+}
+
+func (store *LocalStore) OpenPartial(name string) (Reader, error) {
+	if !strings.HasPrefix(name, partialPrefix) || filepath.Base(name) != name {
+		return nil, fmt.Errorf("invalid partial object name")
+	}
+
+	return os.Open(filepath.Join(store.root, "tmp", name))
+}
+
+func (store *LocalStore) objectPath(key string) string {
+	return filepath.Join(store.root, key[:2], key)
+}
+
+// Prefix of the names of objects that are being written.
+const partialPrefix = "object-"
+
+type localWriter struct {
+	store *LocalStore
+	file  *os.File
+	hash  hash.Hash
+	size  int64
+}
+
+func (writer *localWriter) Write(p []byte) (int, error) {
+	n, err := writer.file.Write(p)
+	writer.hash.Write(p[:n])
+	writer.size += int64(n)
+	return n, err
+}
+
+func (writer *localWriter) Commit() (Object, error) {
+	object := Object{
+		Key:  hex.EncodeToString(writer.hash.Sum(nil)),
+		Size: writer.size,
+	}
+
+	if err := writer.file.Close(); err != nil {
+		os.Remove(writer.file.Name())
+		return object, err
+	}
+
+	path := writer.store.objectPath(object.Key)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		os.Remove(writer.file.Name())
+		return object, err
+	}
+
+	// same content is already stored
+	if _, err := os.Stat(path); err == nil {
+		return object, nil
+	}
+
+	// partial file is kept for readers that don't know the key yet
+	if err := os.Link(writer.file.Name(), path); err != nil {
+		os.Remove(writer.file.Name())
+		return object, err
+	}
+
+	return object, nil
+}
+
+func (writer *localWriter) Release() error {
+	err := os.Remove(writer.file.Name())
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+
+	return err
+}
+
+func (writer *localWriter) Partial() string {
+	return filepath.Base(writer.file.Name())
+}
+
+func (writer *localWriter) Abort() error {
+	writer.file.Close()
+	return os.Remove(writer.file.Name())
+}
+
+// Checks whether provided string can be a key of the stored object.
+func IsValidKey(key string) bool {
+	if len(key) != sha256.Size*2 {
+		return false
+	}
+
+	_, err := hex.DecodeString(key)
+	return err == nil
+}
+
+// Returns offset from which last lines of the content are starting.
+// Trailing line break doesn't start a new line.
+func TailOffset(reader io.ReaderAt, size int64, lines int) (int64, error) {
+	if lines <= 0 {
+		return size, nil
+	}
+
+	chunk := make([]byte, 4096)
+	end := size
+	found := 0
+	for end > 0 {
+		start := max(end-int64(len(chunk)), 0)
+		n, err := reader.ReadAt(chunk[:end-start], start)
+		if err != nil && err != io.EOF {
+			return 0, err
+		}
+
+		for i := n - 1; i >= 0; i-- {
+			position := start + int64(i)
+			if chunk[i] != '\n' || position == size-1 {
+				continue
+			}
+
+			found++
+			if found == lines {
+				return position + 1, nil
+			}
+		}
+
+		end = start
+	}
+
+	return 0, nil
+}
+
+// Writer that keeps written data in memory until its size exceeds
+// threshold. After that everything is moved into the Store and all
+// next writes are going there.
+//
+// Safe for concurrent use.
+type SpillBuffer struct {
+	store     Store
+	threshold int
+
+	locker sync.Mutex
+	buffer bytes.Buffer
+	writer Writer
+	size   int64
+	err    error
+}
+
+// Creates new SpillBuffer. If store is nil - data is never spilled.
+func NewSpillBuffer(store Store, threshold int) *SpillBuffer {
+	buffer := new(SpillBuffer)
+	buffer.store = store
+	buffer.threshold = threshold
+	return buffer
+}
+
+func (buffer *SpillBuffer) Write(p []byte) (int, error) {
+	buffer.locker.Lock()
+	defer buffer.locker.Unlock()
+
+	if buffer.err != nil {
+		return 0, buffer.err
+	}
+
+	if buffer.writer == nil &&
+		buffer.store != nil &&
+		buffer.buffer.Len()+len(p) > buffer.threshold {
+		buffer.err = buffer.spill()
+		if buffer.err != nil {
+			return 0, buffer.err
+		}
+	}
+
+	var n int
+	if buffer.writer != nil {
+		n, buffer.err = buffer.writer.Write(p)
+	} else {
+		n, buffer.err = buffer.buffer.Write(p)
+	}
+	buffer.size += int64(n)
+
+	return n, buffer.err
+}
+
+// Returns copy of the data kept in memory and total amount of written
+// bytes. If data is spilled into the store - returned data is nil and
+// partial name of the object is returned instead.
+func (buffer *SpillBuffer) Snapshot() ([]byte, string, int64) {
+	buffer.locker.Lock()
+	defer buffer.locker.Unlock()
+
+	if buffer.writer != nil {
+		return nil, buffer.writer.Partial(), buffer.size
+	}
+
+	return bytes.Clone(buffer.buffer.Bytes()), "", buffer.size
+}
+
+// Finishes writing. Returns either data kept in memory or the object it
+// was spilled into. Spilled object stays readable by its partial name
+// until Release.
+func (buffer *SpillBuffer) Close() ([]byte, *Object, error) {
+	buffer.locker.Lock()
+	defer buffer.locker.Unlock()
+
+	if buffer.writer == nil {
+		return bytes.Clone(buffer.buffer.Bytes()), nil, buffer.err
+	}
+
+	if buffer.err != nil {
+		buffer.writer.Abort()
+		return nil, nil, buffer.err
+	}
+
+	object, err := buffer.writer.Commit()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return nil, &object, nil
+}
+
+// Removes partial name of the spilled object after Close.
+func (buffer *SpillBuffer) Release() error {
+	buffer.locker.Lock()
+	defer buffer.locker.Unlock()
+
+	if buffer.writer == nil {
+		return nil
+	}
+
+	return buffer.writer.Release()
+}
+
+func (buffer *SpillBuffer) spill() error {
+	writer, err := buffer.store.Create()
+	if err != nil {
+		return err
+	}
+
+	if _, err := writer.Write(buffer.buffer.Bytes()); err != nil {
+		writer.Abort()
+		return err
+	}
+
+	buffer.buffer = bytes.Buffer{}
+	buffer.writer = writer
+	return nil
+}
@@ -0,0 +1,184 @@
+package storage
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"strings"
+	"testing"
+)
+
+func TestLocalStoreCommitAndOpen(t *testing.T) {
+	store, err := NewLocalStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("store must be created, got \"%s\"", err)
+	}
+
+	writer, err := store.Create()
+	if err != nil {
+		t.Fatalf("writer must be created, got \"%s\"", err)
+	}
+	io.WriteString(writer, "amogus")
+
+	object, err := writer.Commit()
+	if err != nil {
+		t.Fatalf("commit had to return nil, but returned \"%s\"", err)
+	}
+	if object.Size != 6 {
+		t.Fatalf("object size must be 6, got %d", object.Size)
+	}
+	if !IsValidKey(object.Key) {
+		t.Fatalf("object key must be valid, got \"%s\"", object.Key)
+	}
+
+	reader, err := store.Open(object.Key)
+	if err != nil {
+		t.Fatalf("object must be opened, got \"%s\"", err)
+	}
+	defer reader.Close()
+
+	content, _ := io.ReadAll(reader)
+	if string(content) != "amogus" {
+		t.Fatalf("object content must be \"amogus\", got \"%s\"", content)
+	}
+}
+
+func TestLocalStoreSameContent(t *testing.T) {
+	store, _ := NewLocalStore(t.TempDir())
+
+	keys := make([]string, 0, 2)
+	for i := 0; i < 2; i++ {
+		writer, _ := store.Create()
+		io.WriteString(writer, "sus")
+
+		object, err := writer.Commit()
+		if err != nil {
+			t.Fatalf("commit had to return nil, but returned \"%s\"", err)
+		}
+		keys = append(keys, object.Key)
+	}
+
+	if keys[0] != keys[1] {
+		t.Fatalf("same content must have same keys, got \"%s\" and \"%s\"", keys[0], keys[1])
+	}
+}
+
+func TestLocalStoreInvalidKey(t *testing.T) {
+	store, _ := NewLocalStore(t.TempDir())
+
+	if _, err := store.Open("../../etc/passwd"); err == nil {
+		t.Fatalf("invalid key must not be opened")
+	}
+}
+
+func TestLocalStoreInvalidPartialName(t *testing.T) {
+	store, _ := NewLocalStore(t.TempDir())
+
+	for _, name := range []string{"", "../../etc/passwd", "object-/../../etc/passwd", "amogus"} {
+		if _, err := store.OpenPartial(name); err == nil || errors.Is(err, fs.ErrNotExist) {
+			t.Fatalf("invalid partial name \"%s\" must be rejected, got \"%v\"", name, err)
+		}
+	}
+}
+
+func TestSpillBufferInMemory(t *testing.T) {
+	store, _ := NewLocalStore(t.TempDir())
+	buffer := NewSpillBuffer(store, 10)
+
+	io.WriteString(buffer, "amogus")
+
+	data, object, err := buffer.Close()
+	if err != nil {
+		t.Fatalf("close had to return nil, but returned \"%s\"", err)
+	}
+	if object != nil {
+		t.Fatalf("small data must not be spilled")
+	}
+	if string(data) != "amogus" {
+		t.Fatalf("data must be \"amogus\", got \"%s\"", data)
+	}
+}
+
+func TestSpillBufferSpilled(t *testing.T) {
+	store, _ := NewLocalStore(t.TempDir())
+	buffer := NewSpillBuffer(store, 10)
+
+	io.WriteString(buffer, "amogus")
+	io.WriteString(buffer, strings.Repeat("sus", 5))
+
+	data, partial, size := buffer.Snapshot()
+	if data != nil {
+		t.Fatalf("snapshot of spilled buffer must be nil, got \"%s\"", data)
+	}
+	if size != 21 {
+		t.Fatalf("size must be 21, got %d", size)
+	}
+
+	partialReader, err := store.OpenPartial(partial)
+	if err != nil {
+		t.Fatalf("spilled data must be readable before commit, got \"%s\"", err)
+	}
+	content, _ := io.ReadAll(partialReader)
+	partialReader.Close()
+	if string(content) != "amogus"+strings.Repeat("sus", 5) {
+		t.Fatalf("partial content is wrong, got \"%s\"", content)
+	}
+
+	data, object, err := buffer.Close()
+	if err != nil {
+		t.Fatalf("close had to return nil, but returned \"%s\"", err)
+	}
+	if data != nil || object == nil {
+		t.Fatalf("large data must be spilled")
+	}
+
+	partialReader, err = store.OpenPartial(partial)
+	if err != nil {
+		t.Fatalf("committed object must be partial until release, got \"%s\"", err)
+	}
+	partialReader.Close()
+
+	if err := buffer.Release(); err != nil {
+		t.Fatalf("release had to return nil, but returned \"%s\"", err)
+	}
+	if _, err := store.OpenPartial(partial); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("released object must not be partial, got \"%v\"", err)
+	}
+
+	reader, _ := store.Open(object.Key)
+	defer reader.Close()
+
+	content, _ = io.ReadAll(reader)
+	if string(content) != "amogus"+strings.Repeat("sus", 5) {
+		t.Fatalf("stored content is wrong, got \"%s\"", content)
+	}
+}
+
+func TestTailOffset(t *testing.T) {
+	content := "amogus\nsus\nimpostor\n"
+	reader := bytes.NewReader([]byte(content))
+
+	offset, err := TailOffset(reader, int64(len(content)), 2)
+	if err != nil {
+		t.Fatalf("tail had to return nil, but returned \"%s\"", err)
+	}
+	if content[offset:] != "sus\nimpostor\n" {
+		t.Fatalf("last 2 lines must be \"sus\\nimpostor\\n\", got \"%s\"", content[offset:])
+	}
+
+	offset, _ = TailOffset(reader, int64(len(content)), 10)
+	if offset != 0 {
+		t.Fatalf("offset of more lines than content has must be 0, got %d", offset)
+	}
+}
+
+func TestTailOffsetLargeContent(t *testing.T) {
+	content := strings.Repeat("sus", 5000) + "\n" + strings.Repeat("amogus", 1000)
+	reader := bytes.NewReader([]byte(content))
+
+	offset, _ := TailOffset(reader, int64(len(content)), 1)
+	if content[offset:] != strings.Repeat("amogus", 1000) {
+		t.Fatalf("last line is wrong, got offset %d", offset)
+	}
+}
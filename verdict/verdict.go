@@ -0,0 +1,112 @@
+package verdict
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"slices"
+)
+
+// Verdicts of the finished command
+const (
+	Succeeded = "succeeded"
+	Failed    = "failed"
+)
+
+// Streams of the command that can be checked
+const (
+	StreamStdout = "stdout"
+	StreamStderr = "stderr"
+)
+
+// Criteria of the command's success. By default command is succeeded if
+// its exit code is 0.
+type Criteria struct {
+	// Exit codes of the succeeded command. If empty - only 0 is accepted.
+	ExitCodes []int `json:"exit_codes"`
+
+	Assertions []Assertion `json:"assertions"`
+}
+
+// Assertion about output of the command: regular expression that must or
+// must not match one of the streams.
+type Assertion struct {
+	Stream       string `json:"stream"`
+	MustMatch    string `json:"must_match,omitempty"`
+	MustNotMatch string `json:"must_not_match,omitempty"`
+
+	regexp *regexp.Regexp
+}
+
+// Function that opens stream of the finished command.
+type Opener func(stream string) (io.ReadCloser, error)
+
+// Checks criteria and compiles regular expressions of the assertions. Must
+// be called before Evaluate.
+func (criteria *Criteria) Validate() error {
+	for i := range criteria.Assertions {
+		assertion := &criteria.Assertions[i]
+
+		switch assertion.Stream {
+		case StreamStdout, StreamStderr:
+		default:
+			return fmt.Errorf("unknown stream \"%s\" of the assertion", assertion.Stream)
+		}
+
+		pattern := assertion.MustMatch
+		if (assertion.MustMatch == "") == (assertion.MustNotMatch == "") {
+			return fmt.Errorf("exactly one of \"must_match\" and \"must_not_match\" must be provided")
+		}
+		if pattern == "" {
+			pattern = assertion.MustNotMatch
+		}
+
+		var err error
+		assertion.regexp, err = regexp.Compile(pattern)
+		if err != nil {
+			return fmt.Errorf("invalid pattern \"%s\": %w", pattern, err)
+		}
+	}
+
+	return nil
+}
+
+// Returns verdict of the command finished with provided exit code and
+// message that explains failed verdict. Streams are opened only if exit
+// code is accepted.
+func (criteria *Criteria) Evaluate(exitCode int, open Opener) (string, string, error) {
+	exitCodes := criteria.ExitCodes
+	if len(exitCodes) == 0 {
+		exitCodes = []int{0}
+	}
+	if !slices.Contains(exitCodes, exitCode) {
+		return Failed, fmt.Sprintf("exit code %d isn't accepted", exitCode), nil
+	}
+
+	for _, assertion := range criteria.Assertions {
+		matched, err := assertion.match(open)
+		if err != nil {
+			return Failed, "", err
+		}
+
+		switch {
+		case assertion.MustMatch != "" && !matched:
+			return Failed, fmt.Sprintf("%s doesn't match \"%s\"", assertion.Stream, assertion.MustMatch), nil
+		case assertion.MustNotMatch != "" && matched:
+			return Failed, fmt.Sprintf("%s matches \"%s\"", assertion.Stream, assertion.MustNotMatch), nil
+		}
+	}
+
+	return Succeeded, "", nil
+}
+
+func (assertion *Assertion) match(open Opener) (bool, error) {
+	stream, err := open(assertion.Stream)
+	if err != nil {
+		return false, err
+	}
+	defer stream.Close()
+
+	return assertion.regexp.MatchReader(bufio.NewReader(stream)), nil
+}
@@ -0,0 +1,81 @@
+package verdict
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+func newOpener(stdout, stderr string) Opener {
+	return func(stream string) (io.ReadCloser, error) {
+		if stream == StreamStdout {
+			return io.NopCloser(strings.NewReader(stdout)), nil
+		}
+		return io.NopCloser(strings.NewReader(stderr)), nil
+	}
+}
+
+func TestValidate(t *testing.T) {
+	invalid := []Criteria{
+		{Assertions: []Assertion{{Stream: "stdin", MustMatch: "sus"}}},
+		{Assertions: []Assertion{{Stream: StreamStdout}}},
+		{Assertions: []Assertion{{Stream: StreamStdout, MustMatch: "sus", MustNotMatch: "amogus"}}},
+		{Assertions: []Assertion{{Stream: StreamStdout, MustMatch: "("}}},
+	}
+
+	for _, criteria := range invalid {
+		if err := criteria.Validate(); err == nil {
+			t.Fatalf("criteria %+v must be rejected", criteria)
+		}
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	criteria := Criteria{
+		ExitCodes: []int{0, 1},
+		Assertions: []Assertion{
+			{Stream: StreamStdout, MustMatch: `^amogus`},
+			{Stream: StreamStderr, MustNotMatch: `(?i)error`},
+		},
+	}
+	if err := criteria.Validate(); err != nil {
+		t.Fatalf("validate had to return nil, but returned \"%s\"", err)
+	}
+
+	cases := []struct {
+		exitCode int
+		stdout   string
+		stderr   string
+		expected string
+	}{
+		{0, "amogus is sus", "", Succeeded},
+		{1, "amogus", "warning", Succeeded},
+		{2, "amogus", "", Failed},
+		{0, "sus", "", Failed},
+		{0, "amogus", "ERROR: sus", Failed},
+	}
+
+	for _, c := range cases {
+		verdict, message, err := criteria.Evaluate(c.exitCode, newOpener(c.stdout, c.stderr))
+		if err != nil {
+			t.Fatalf("evaluate had to return nil, but returned \"%s\"", err)
+		}
+		if verdict != c.expected {
+			t.Fatalf("verdict of %+v must be %s, got %s (%s)", c, c.expected, verdict, message)
+		}
+		if verdict == Failed && message == "" {
+			t.Fatalf("failed verdict must have a message")
+		}
+	}
+}
+
+func TestEvaluateDefault(t *testing.T) {
+	var criteria Criteria
+
+	if verdict, _, _ := criteria.Evaluate(0, newOpener("", "")); verdict != Succeeded {
+		t.Fatalf("exit code 0 must be succeeded by default")
+	}
+	if verdict, _, _ := criteria.Evaluate(1, newOpener("", "")); verdict != Failed {
+		t.Fatalf("exit code 1 must be failed by default")
+	}
+}
@@ -0,0 +1,325 @@
+package workspace
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// Default mode of the files written into the workspace
+const DefaultFileMode fs.FileMode = 0o644
+
+// Struct that describes file materialized in the workspace.
+type File struct {
+	Path   string      `json:"path"`
+	Mode   fs.FileMode `json:"mode"`
+	Size   int64       `json:"size"`
+	Sha256 string      `json:"sha256"`
+}
+
+// Directory where per-command workspaces are created.
+type Manager struct {
+	root string
+
+	// directory with templates that new workspaces can be seeded from
+	templates string
+
+	// canonical directories where commands are allowed to be launched
+	allowedRoots []string
+}
+
+// Creates new Manager in provided directory. Directory will be created if
+// it doesn't exist. If templates directory is empty - workspaces can't be
+// seeded from templates.
+//
+// Working directories requested by clients must be inside of one of the
+// allowed roots. If there are no allowed roots - no working directory is
+// allowed, "/" must be allowed explicitly to allow any of them.
+func NewManager(root, templates string, allowedRoots []string) (*Manager, error) {
+	if root == "" {
+		return nil, fmt.Errorf("workspaces root can't be empty")
+	}
+
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return nil, err
+	}
+
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+
+	manager := new(Manager)
+	manager.root = root
+	manager.templates = templates
+
+	for _, allowedRoot := range allowedRoots {
+		canonical, err := canonicalize(allowedRoot)
+		if err != nil {
+			return nil, fmt.Errorf("allowed root \"%s\" is invalid: %w", allowedRoot, err)
+		}
+
+		manager.allowedRoots = append(manager.allowedRoots, canonical)
+	}
+
+	return manager, nil
+}
+
+// Returns canonical path of requested working directory: absolute and
+// with resolved symlinks. Fails if directory doesn't exist or is outside
+// of allowed roots.
+func (manager *Manager) ResolveWorkdir(workdir string) (string, error) {
+	canonical, err := canonicalize(workdir)
+	if err != nil {
+		return "", fmt.Errorf("workdir \"%s\" is invalid: %w", workdir, err)
+	}
+
+	if len(manager.allowedRoots) == 0 {
+		return "", fmt.Errorf("workdirs are not allowed on this server, use workspace instead")
+	}
+
+	for _, allowedRoot := range manager.allowedRoots {
+		if isInside(allowedRoot, canonical) {
+			return canonical, nil
+		}
+	}
+
+	return "", fmt.Errorf("workdir \"%s\" is outside of allowed directories", workdir)
+}
+
+// Creates new workspace and returns its absolute path. If template isn't
+// empty - content of the template directory with such name is copied into
+// the workspace.
+func (manager *Manager) Create(template string) (string, error) {
+	var templateDir string
+	if template != "" {
+		if manager.templates == "" {
+			return "", fmt.Errorf("templates are not configured")
+		}
+		if !filepath.IsLocal(template) {
+			return "", fmt.Errorf("invalid template name \"%s\"", template)
+		}
+
+		templateDir = filepath.Join(manager.templates, template)
+		if info, err := os.Stat(templateDir); err != nil || !info.IsDir() {
+			return "", fmt.Errorf("template \"%s\" doesn't exist", template)
+		}
+	}
+
+	dir, err := os.MkdirTemp(manager.root, "command-*")
+	if err != nil {
+		return "", err
+	}
+
+	if templateDir != "" {
+		if err := CopyDir(templateDir, dir); err != nil {
+			os.RemoveAll(dir)
+			return "", err
+		}
+	}
+
+	return dir, nil
+}
+
+// Removes workspace with all of its content.
+func (manager *Manager) Remove(dir string) error {
+	if filepath.Dir(dir) != manager.root {
+		return fmt.Errorf("\"%s\" is not a workspace", dir)
+	}
+
+	return os.RemoveAll(dir)
+}
+
+// Returns absolute path of existing directory with resolved symlinks.
+func canonicalize(dir string) (string, error) {
+	absolute, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+
+	canonical, err := filepath.EvalSymlinks(absolute)
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", fmt.Errorf("directory doesn't exist")
+	}
+	if err != nil {
+		return "", err
+	}
+
+	info, err := os.Stat(canonical)
+	if err != nil {
+		return "", err
+	}
+	if !info.IsDir() {
+		return "", fmt.Errorf("not a directory")
+	}
+
+	return canonical, nil
+}
+
+// Checks whether path is the root or is inside of it. Both paths must be
+// canonical.
+func isInside(root, path string) bool {
+	relative, err := filepath.Rel(root, path)
+	return err == nil && filepath.IsLocal(relative)
+}
+
+// Copies directories and regular files from src into existing dst
+// preserving their modes. Symlinks and special files are skipped.
+func CopyDir(src, dst string) error {
+	return filepath.WalkDir(src, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		relative, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, relative)
+
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case entry.IsDir():
+			return os.MkdirAll(target, info.Mode().Perm())
+		case info.Mode().IsRegular():
+			return copyFile(path, target, info.Mode().Perm())
+		default:
+			return nil
+		}
+	})
+}
+
+func copyFile(src, dst string, mode fs.FileMode) error {
+	input, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer input.Close()
+
+	output, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, mode)
+	if err != nil {
+		return err
+	}
+	defer output.Close()
+
+	if _, err := io.Copy(output, input); err != nil {
+		return err
+	}
+
+	return output.Chmod(mode)
+}
+
+// Writes content of the reader into the file with provided relative path
+// inside of the dir. Missing parent directories are created.
+//
+// Path must be local: absolute paths and paths that escape the dir
+// are rejected.
+func WriteFile(dir, path string, mode fs.FileMode, reader io.Reader) (File, error) {
+	file := File{Path: filepath.ToSlash(filepath.Clean(path)), Mode: mode}
+
+	if !filepath.IsLocal(path) {
+		return file, fmt.Errorf("path \"%s\" must be relative and must not leave working directory", path)
+	}
+	if mode&^fs.ModePerm != 0 {
+		return file, fmt.Errorf("mode %o is not allowed", mode)
+	}
+
+	fullPath := filepath.Join(dir, path)
+	if err := os.MkdirAll(filepath.Dir(fullPath), 0o755); err != nil {
+		return file, err
+	}
+
+	// O_EXCL prevents following symlinks created by previous files
+	output, err := os.OpenFile(fullPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, mode)
+	if err != nil {
+		return file, err
+	}
+	defer output.Close()
+
+	hash := sha256.New()
+	file.Size, err = io.Copy(io.MultiWriter(output, hash), reader)
+	if err != nil {
+		return file, err
+	}
+	file.Sha256 = hex.EncodeToString(hash.Sum(nil))
+
+	// mode passed to OpenFile is affected by umask
+	return file, output.Chmod(mode)
+}
+
+// Returns relative paths of regular files inside of the dir that match
+// any of the patterns. Patterns are relative to the dir and use syntax of
+// filepath.Match. Patterns that leave the dir are rejected, files that
+// are reached through symlinks leaving the dir are skipped.
+func Glob(dir string, patterns []string) ([]string, error) {
+	root, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var paths []string
+	seen := make(map[string]bool)
+
+	for _, pattern := range patterns {
+		if !filepath.IsLocal(pattern) {
+			return nil, fmt.Errorf("pattern \"%s\" must be relative and must not leave working directory", pattern)
+		}
+
+		matches, err := filepath.Glob(filepath.Join(dir, pattern))
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern \"%s\"", pattern)
+		}
+
+		for _, match := range matches {
+			// symlinks are skipped to not collect files outside of the dir
+			info, err := os.Lstat(match)
+			if err != nil || !info.Mode().IsRegular() {
+				continue
+			}
+			// symlinked directories in the middle of the path must not
+			// leave the dir as well
+			canonical, err := filepath.EvalSymlinks(match)
+			if err != nil || !isInside(root, canonical) {
+				continue
+			}
+
+			path, err := filepath.Rel(dir, match)
+			if err != nil {
+				return nil, err
+			}
+			path = filepath.ToSlash(path)
+
+			if !seen[path] {
+				seen[path] = true
+				paths = append(paths, path)
+			}
+		}
+	}
+
+	return paths, nil
+}
+
+// Parses octal file mode like "0755". Empty string is parsed as
+// DefaultFileMode.
+func ParseMode(value string) (fs.FileMode, error) {
+	if value == "" {
+		return DefaultFileMode, nil
+	}
+
+	mode, err := strconv.ParseUint(value, 8, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid file mode \"%s\"", value)
+	}
+
+	return fs.FileMode(mode), nil
+}
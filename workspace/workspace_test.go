@@ -0,0 +1,185 @@
+package workspace
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWriteFile(t *testing.T) {
+	dir := t.TempDir()
+
+	file, err := WriteFile(dir, "sus/amogus.sh", 0o755, strings.NewReader("echo amogus"))
+	if err != nil {
+		t.Fatalf("file must be written, got \"%s\"", err)
+	}
+	if file.Path != "sus/amogus.sh" {
+		t.Fatalf("path must be \"sus/amogus.sh\", got \"%s\"", file.Path)
+	}
+	if file.Size != 11 {
+		t.Fatalf("size must be 11, got %d", file.Size)
+	}
+
+	info, err := os.Stat(filepath.Join(dir, "sus", "amogus.sh"))
+	if err != nil {
+		t.Fatalf("file must exist, got \"%s\"", err)
+	}
+	if info.Mode().Perm() != 0o755 {
+		t.Fatalf("mode must be 755, got %o", info.Mode().Perm())
+	}
+}
+
+func TestWriteFileTraversal(t *testing.T) {
+	dir := t.TempDir()
+
+	for _, path := range []string{"../amogus", "/etc/amogus", "sus/../../amogus", ""} {
+		if _, err := WriteFile(dir, path, DefaultFileMode, strings.NewReader("")); err == nil {
+			t.Fatalf("path \"%s\" must be rejected", path)
+		}
+	}
+}
+
+func TestWriteFileSpecialMode(t *testing.T) {
+	dir := t.TempDir()
+
+	if _, err := WriteFile(dir, "amogus", 0o4755, strings.NewReader("")); err == nil {
+		t.Fatalf("setuid mode must be rejected")
+	}
+}
+
+func TestParseMode(t *testing.T) {
+	mode, err := ParseMode("0700")
+	if err != nil || mode != 0o700 {
+		t.Fatalf("mode must be 700, got %o (%v)", mode, err)
+	}
+
+	mode, _ = ParseMode("")
+	if mode != DefaultFileMode {
+		t.Fatalf("empty mode must be default, got %o", mode)
+	}
+
+	if _, err := ParseMode("amogus"); err == nil {
+		t.Fatalf("invalid mode must be rejected")
+	}
+}
+
+func TestGlob(t *testing.T) {
+	dir := t.TempDir()
+
+	WriteFile(dir, "amogus.txt", DefaultFileMode, strings.NewReader("amogus"))
+	WriteFile(dir, "reports/sus.html", DefaultFileMode, strings.NewReader("sus"))
+	WriteFile(dir, "reports/sus.txt", DefaultFileMode, strings.NewReader("sus"))
+	os.Symlink("/etc/passwd", filepath.Join(dir, "passwd.txt"))
+
+	outside := t.TempDir()
+	WriteFile(outside, "secret.txt", DefaultFileMode, strings.NewReader("sus"))
+	os.Symlink(outside, filepath.Join(dir, "outside"))
+	os.Symlink(filepath.Join(dir, "reports"), filepath.Join(dir, "inside"))
+
+	paths, err := Glob(dir, []string{"*.txt", "reports/*.html", "amogus.txt", "outside/*.txt", "inside/*.html"})
+	if err != nil {
+		t.Fatalf("glob had to return nil, but returned \"%s\"", err)
+	}
+	if strings.Join(paths, ",") != "amogus.txt,reports/sus.html,inside/sus.html" {
+		t.Fatalf("paths must be \"amogus.txt,reports/sus.html,inside/sus.html\", got \"%s\"", strings.Join(paths, ","))
+	}
+
+	if _, err := Glob(dir, []string{"../*"}); err == nil {
+		t.Fatalf("pattern that leaves dir must be rejected")
+	}
+}
+
+func TestManagerCreateFromTemplate(t *testing.T) {
+	templates := t.TempDir()
+	WriteFile(templates, "amogus/sus/run.sh", 0o755, strings.NewReader("echo sus"))
+	os.Symlink("/etc/passwd", filepath.Join(templates, "amogus", "passwd"))
+
+	manager, err := NewManager(t.TempDir(), templates, nil)
+	if err != nil {
+		t.Fatalf("manager must be created, got \"%s\"", err)
+	}
+
+	dir, err := manager.Create("amogus")
+	if err != nil {
+		t.Fatalf("workspace must be created, got \"%s\"", err)
+	}
+
+	info, err := os.Stat(filepath.Join(dir, "sus", "run.sh"))
+	if err != nil {
+		t.Fatalf("template file must be copied, got \"%s\"", err)
+	}
+	if info.Mode().Perm() != 0o755 {
+		t.Fatalf("mode must be 755, got %o", info.Mode().Perm())
+	}
+	if _, err := os.Lstat(filepath.Join(dir, "passwd")); err == nil {
+		t.Fatalf("symlinks must not be copied")
+	}
+
+	if _, err := manager.Create("../amogus"); err == nil {
+		t.Fatalf("template outside of templates directory must be rejected")
+	}
+
+	if err := manager.Remove(dir); err != nil {
+		t.Fatalf("workspace must be removed, got \"%s\"", err)
+	}
+	if _, err := os.Stat(dir); err == nil {
+		t.Fatalf("workspace must not exist after removal")
+	}
+}
+
+func TestManagerResolveWorkdir(t *testing.T) {
+	allowed, _ := filepath.EvalSymlinks(t.TempDir())
+	os.Mkdir(filepath.Join(allowed, "amogus"), 0o755)
+	os.Symlink("/etc", filepath.Join(allowed, "sus"))
+	WriteFile(allowed, "impostor", DefaultFileMode, strings.NewReader(""))
+
+	manager, err := NewManager(t.TempDir(), "", []string{allowed})
+	if err != nil {
+		t.Fatalf("manager must be created, got \"%s\"", err)
+	}
+
+	if _, err := manager.ResolveWorkdir(allowed); err != nil {
+		t.Fatalf("allowed root itself must be allowed, got \"%s\"", err)
+	}
+
+	workdir, err := manager.ResolveWorkdir(filepath.Join(allowed, "amogus", "..", "amogus"))
+	if err != nil {
+		t.Fatalf("directory inside of allowed root must be allowed, got \"%s\"", err)
+	}
+	if workdir != filepath.Join(allowed, "amogus") {
+		t.Fatalf("workdir must be canonical, got \"%s\"", workdir)
+	}
+
+	for _, path := range []string{
+		"/etc",
+		filepath.Join(allowed, ".."),
+		filepath.Join(allowed, "sus"),
+		filepath.Join(allowed, "impostor"),
+		filepath.Join(allowed, "missing"),
+	} {
+		if _, err := manager.ResolveWorkdir(path); err == nil {
+			t.Fatalf("workdir \"%s\" must be rejected", path)
+		}
+	}
+}
+
+func TestManagerResolveWorkdirWithoutAllowedRoots(t *testing.T) {
+	manager, err := NewManager(t.TempDir(), "", nil)
+	if err != nil {
+		t.Fatalf("manager must be created, got \"%s\"", err)
+	}
+
+	if _, err := manager.ResolveWorkdir("/etc"); err == nil {
+		t.Fatalf("workdir must be rejected if there are no allowed roots")
+	}
+
+	manager, err = NewManager(t.TempDir(), "", []string{"/"})
+	if err != nil {
+		t.Fatalf("manager must be created, got \"%s\"", err)
+	}
+
+	if _, err := manager.ResolveWorkdir("/etc"); err != nil {
+		t.Fatalf("any workdir must be allowed with \"/\" root, got \"%s\"", err)
+	}
+}